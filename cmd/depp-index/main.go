@@ -16,10 +16,12 @@ import (
 )
 
 type Repo struct {
-	Name     string
-	Title    string
-	Desc     string
-	Modified time.Time
+	Name      string
+	Stripped  string // Name without .git extension
+	Title     string
+	Desc      string
+	CloneURLs []gitweb.CloneURL
+	Modified  time.Time
 }
 
 type Page struct {
@@ -40,6 +42,8 @@ var (
 	dest  = flag.String("d", "./www", "output directory for HTML files")
 	strip = flag.Bool("x", false, "strip .git extension from repository name in link")
 	items = flag.Int("p", 20, "amount of repos per HTML page, a zero value disables pagination")
+
+	gitURLs gitweb.CloneURLList
 )
 
 func usage() {
@@ -54,7 +58,7 @@ func usage() {
 func repoLink(repo *Repo) string {
 	if *strip {
 		// Return a post-processed repository name without .git
-		return repo.Title
+		return repo.Stripped
 	} else {
 		// Return the raw file name, potentially including .git
 		return repo.Name
@@ -106,10 +110,10 @@ func createHTML(page Page, path string) error {
 	return nil
 }
 
-func getRepos(fps []string) ([]Repo, error) {
+func getRepos(fps []string, cloneURLs []gitweb.CloneURL) ([]Repo, error) {
 	repos := make([]Repo, len(fps))
 	for i, fp := range fps {
-		r, err := gitweb.NewRepo(fp, nil, 0)
+		r, err := gitweb.NewRepo(fp, cloneURLs, 0)
 		if err != nil {
 			return []Repo{}, err
 		}
@@ -125,10 +129,12 @@ func getRepos(fps []string) ([]Repo, error) {
 
 		sig := commit.Committer
 		repos[i] = Repo{
-			Name:     filepath.Base(fp),
-			Title:    r.Title,
-			Desc:     desc,
-			Modified: sig.When,
+			Name:      filepath.Base(fp),
+			Stripped:  r.Name,
+			Title:     r.Title,
+			Desc:      desc,
+			CloneURLs: r.CloneURLs,
+			Modified:  sig.When,
 		}
 	}
 
@@ -169,6 +175,7 @@ func getPages(repos []Repo) []Page {
 }
 
 func main() {
+	flag.Var(&gitURLs, "u", "clone `URL` template for the repositories, optionally preceded by a label")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -177,7 +184,7 @@ func main() {
 		usage()
 	}
 
-	repos, err := getRepos(flag.Args())
+	repos, err := getRepos(flag.Args(), gitURLs)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"git.8pit.net/depp/gitweb"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// Name of the directory containing the generated source archives.
+	archiveDir = "archive"
+	// Name of the subdirectory of archiveDir containing the archives for
+	// HEAD, which keeps them apart from the archives for tags.
+	snapshotDir = "head"
+)
+
+type archiveFormat struct {
+	Ext   string
+	write func(io.Writer, *object.Commit, string) error
+}
+
+var archiveFormats = []archiveFormat{
+	{"tar.gz", gitweb.WriteTarGz},
+	{"zip", gitweb.WriteZip},
+}
+
+// Returns the repository title with all characters, which are not safe to
+// use in file names, replaced.
+func archiveTitle(repo *gitweb.Repo) string {
+	title := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+-._", r) {
+			return r
+		}
+		return '-'
+	}, repo.Title)
+
+	title = strings.TrimLeft(title, ".")
+	if title == "" {
+		return archiveDir
+	}
+	return title
+}
+
+// Returns the slash separated directory, relative to the destination
+// directory, and the base name, without extension, of the archives for
+// the given tag or, if the tag is empty, for HEAD. The name is also used
+// as the archive prefix. Slashes in tag names are replaced with a tilde,
+// which git does not permit in tag names.
+func archiveName(repo *gitweb.Repo, tag string) (string, string) {
+	if tag == "" {
+		return archiveDir + "/" + snapshotDir, archiveTitle(repo) + "-HEAD"
+	}
+	return archiveDir, archiveTitle(repo) + "-" + strings.ReplaceAll(tag, "/", "~")
+}
+
+// Returns the escaped slash separated paths of all archives for the given
+// tag or HEAD, relative to the destination directory.
+func archivePaths(repo *gitweb.Repo, tag string) []string {
+	dir, name := archiveName(repo, tag)
+
+	var paths []string
+	for _, format := range archiveFormats {
+		paths = append(paths, dir+"/"+url.PathEscape(name+"."+format.Ext))
+	}
+	return paths
+}
+
+// Returns the paths of all archives for the given tag, see archivePaths.
+// If archives are not generated for tags, nil is returned.
+func archives(repo *gitweb.Repo, tag string) []string {
+	if !*archiveTags {
+		return nil
+	}
+	return archivePaths(repo, tag)
+}
+
+// Returns the paths of all archives for HEAD, see archivePaths. If
+// archives are not generated for HEAD, nil is returned.
+func snapshots(repo *gitweb.Repo) []string {
+	if !*archiveHead {
+		return nil
+	}
+	return archivePaths(repo, "")
+}
+
+// Returns the file name extension of the given archive path.
+func archiveExt(fp string) string {
+	for _, format := range archiveFormats {
+		if strings.HasSuffix(fp, "."+format.Ext) {
+			return format.Ext
+		}
+	}
+	return ""
+}
+
+// Writes all archives for the given commit, which is referenced by the
+// given tag or, if the tag is empty, by HEAD. Existing archives are only
+// overwritten if overwrite is true. Returns true if an archive was created.
+func writeArchives(repo *gitweb.Repo, commit *object.Commit, tag string, overwrite bool) (bool, error) {
+	var created bool
+
+	dir, name := archiveName(repo, tag)
+	dir = filepath.FromSlash(dir)
+	for _, format := range archiveFormats {
+		dest := filepath.Join(*destination, dir, name+"."+format.Ext)
+		_, err := os.Stat(dest)
+		if err == nil && !overwrite {
+			continue
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		created = created || err != nil
+
+		if *verbose {
+			fmt.Println(filepath.Join(dir, name+"."+format.Ext))
+		}
+		err = writeAtomic(dest, func(w io.Writer) error {
+			return format.write(w, commit, name)
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return created, nil
+}
+
+// Atomically creates the file dest with the content written by fn.
+func writeAtomic(dest string, fn func(io.Writer) error) error {
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(dest), ".archive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = fn(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Chmod(0644)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), dest)
+}
+
+// Generates archives for all tags, and optionally for HEAD. Returns true
+// if an archive was created which did not exist previously.
+func generateArchives(repo *gitweb.Repo) (bool, error) {
+	var created bool
+
+	if *archiveTags {
+		tags, err := repo.Tags()
+		if err != nil {
+			return false, err
+		}
+
+		for _, tag := range tags {
+			c, err := writeArchives(repo, tag.Commit, tag.Name, false)
+			if err != nil {
+				return false, err
+			}
+			created = created || c
+		}
+	}
+
+	if *archiveHead {
+		tip, err := repo.Tip()
+		if err != nil {
+			return false, err
+		}
+
+		c, err := writeArchives(repo, tip, "", repo.TipChanged())
+		if err != nil {
+			return false, err
+		}
+		created = created || c
+	}
+
+	return created, nil
+}
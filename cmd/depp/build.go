@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"runtime/debug"
+
+	"git.8pit.net/depp/css"
+	"git.8pit.net/depp/gitweb"
+)
+
+// Returns the version of depp, including the VCS revision if available.
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	// Versions of development builds are only derived from the VCS
+	// revision since Go 1.24, add it explicitly for older versions.
+	version := info.Main.Version
+	if version != "(devel)" {
+		return version
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version += " " + setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				version += " modified"
+			}
+		}
+	}
+
+	return version
+}
+
+// Writes the name and content of all files in fsys to h.
+func hashFS(h hash.Hash, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(fp string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		file, err := fsys.Open(fp)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fmt.Fprintf(h, "%s\n", fp)
+		_, err = io.Copy(h, file)
+		return err
+	})
+}
+
+// Returns a description of the build, the generated files need to be
+// rebuild if any option or template which affects them changes.
+func currentBuild(repo *gitweb.Repo) (gitweb.Build, error) {
+	build := gitweb.Build{Version: version()}
+
+	h := sha256.New()
+	for _, fsys := range []fs.FS{templates, css.Templates()} {
+		err := hashFS(h, fsys)
+		if err != nil {
+			return gitweb.Build{}, err
+		}
+	}
+	build.Templates = hex.EncodeToString(h.Sum(nil))
+
+	// The -d, -f, and -v flags do not affect the content of generated files.
+	h.Reset()
+	fmt.Fprintf(h, "%d %d %q %t %t %q %d %d %t %q %t\n", *commits, *feedEntries,
+		gitURLs, *archiveTags, *archiveHead, branches, *logSize, *historySize,
+		*blame, *highlightCmd, *raw)
+	fmt.Fprintf(h, "%#v\n", repo.Conf)
+	build.Options = hex.EncodeToString(h.Sum(nil))
+
+	return build, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"git.8pit.net/depp/gitweb"
+	"git.8pit.net/depp/highlight"
+)
+
+const highlightScript = "git-highlight"
+
+// Returns the highlighter for the given repository, either the executable
+// in the repository or the command passed via the command line, which is
+// split into arguments at whitespace. Nil is returned if neither exists.
+func highlighter(repo *gitweb.Repo) ([]string, error) {
+	fp := filepath.Join(repo.Path, highlightScript)
+	cmd, err := exec.LookPath(fp)
+	if err == nil {
+		return []string{cmd}, nil
+	} else if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, exec.ErrNotFound) {
+		return nil, err
+	}
+
+	return strings.Fields(*highlightCmd), nil
+}
+
+// Returns the highlighted HTML for each line of the preview of the blob of
+// the page. The highlighter receives the path of the file as an argument and
+// the contents on standard input, it must write the HTML for each line to
+// standard output. Without a highlighter, or if it fails, the built-in
+// highlighter is used.
+func highlightBlob(page *gitweb.RepoPage, preview *gitweb.Preview) ([]template.HTML, error) {
+	args, err := highlighter(page.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, contents := page.CurrentFile.Path, preview.Contents
+	if len(args) == 0 {
+		return highlight.Lines(fp, contents), nil
+	}
+
+	lines, err := runHighlighter(args, fp, contents)
+	if err != nil {
+		log.Printf("%s: %v, using built-in highlighter", fp, err)
+		return highlight.Lines(fp, contents), nil
+	}
+
+	result := make([]template.HTML, len(lines))
+	for i, line := range lines {
+		result[i] = template.HTML(line)
+	}
+	return result, nil
+}
+
+// Runs the given highlighter on the file and returns the highlighted lines.
+func runHighlighter(args []string, fp, contents string) ([]string, error) {
+	cmd := exec.Command(args[0], append(args[1:], fp)...)
+	out, err := runWithInput(cmd, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare against the unhighlighted lines to ensure that the line
+	// numbers and anchors are consistent with the file.
+	lines, expected := getLines(out), getLines(contents)
+	if len(lines) != len(expected) {
+		return nil, fmt.Errorf("highlighter returned %d lines, expected %d",
+			len(lines), len(expected))
+	}
+
+	return lines, nil
+}
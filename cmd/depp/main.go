@@ -6,11 +6,13 @@ import (
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
 	"log"
-	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"git.8pit.net/depp/css"
 	"git.8pit.net/depp/gitweb"
@@ -20,20 +22,93 @@ import (
 var templates embed.FS
 
 var (
-	commits     = flag.Uint("c", 5, "amount of recent commits to include")
-	force       = flag.Bool("f", false, "force rebuilding of all HTML files")
-	gitURL      = flag.String("u", "", "clone URL for the Git repository")
-	destination = flag.String("d", "./www", "output directory for HTML files")
-	verbose     = flag.Bool("v", false, "print the name of each changed file")
+	commits      = flag.Uint("c", 5, "amount of recent commits to include")
+	feedEntries  = flag.Uint("e", 20, "amount of recent commits to include in the Atom feed")
+	force        = flag.Bool("f", false, "force rebuilding of all HTML files")
+	destination  = flag.String("d", "./www", "output directory for HTML files")
+	verbose      = flag.Bool("v", false, "print the name of each changed file")
+	archiveTags  = flag.Bool("a", false, "generate source archives for all tags")
+	archiveHead  = flag.Bool("A", false, "generate source archives for HEAD")
+	logSize      = flag.Uint("l", 0, "amount of commits per page of the commit log")
+	historySize  = flag.Uint("H", 0, "amount of commits on history pages of files")
+	blame        = flag.Bool("B", false, "generate blame pages for text files")
+	highlightCmd = flag.String("s", "", "command used for syntax highlighting of files")
+	raw          = flag.Bool("r", false, "write the raw content of files")
+	blobMaxLines = flag.Uint("n", 0, "maximum amount of lines displayed for each file")
+	blobMaxSize  sizeFlag
+	treeMaxSize  sizeFlag
+	branches     stringList
+	gitURLs      gitweb.CloneURLList
 )
 
 var tmpl *template.Template
 
 const (
-	// Name of file used to record the hash of the generated tree object.
-	stateFile = ".tree"
+	// Name of file used to record the state of the generated files.
+	stateFile = ".state"
+
+	// Name of file used to cache the stats of commits across invocations.
+	statsFile = ".stats"
+
+	// Name of file used by older versions to record the state.
+	legacyStateFile = ".tree"
+
+	// Name of the Atom feed containing the most recent commits.
+	feedFile = "atom.xml"
+
+	// Name of the Atom feed containing all tags.
+	tagsFeedFile = "tags.xml"
+
+	// Name of the directory containing the generated commit pages.
+	commitDir = "commit"
+
+	// Name of the directory containing the pages for additional branches.
+	branchDir = "branch"
+
+	// Name of the directory containing the tag overview page.
+	tagsDir = "tags"
+
+	// Name of the directory containing the pages of the commit log.
+	logDir = "log"
+
+	// Name of the directory containing the history pages of files.
+	historyDir = "history"
+
+	// Suffix of the blame page for a file, instead of .html.
+	blameSuffix = ".blame.html"
+
+	// Name of the directory containing the raw content of files.
+	rawDir = "raw"
 )
 
+// stringList is a flag.Value which can be passed multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// sizeFlag is a flag.Value for a size in bytes with an optional suffix.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(value string) error {
+	n, err := gitweb.ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
+
 func usage() {
 	fmt.Fprintf(flag.CommandLine.Output(),
 		"USAGE: %s [FLAGS] REPOSITORY\n\n"+
@@ -43,7 +118,113 @@ func usage() {
 	os.Exit(2)
 }
 
-func walkPages(name string, page *gitweb.RepoPage) error {
+// historyPage is the history of the file of a page.
+type historyPage struct {
+	*gitweb.RepoPage
+}
+
+// blamePage is the blame of the file of a page.
+type blamePage struct {
+	*gitweb.RepoPage
+}
+
+// Returns a WalkFunc which writes pages to the given subdirectory of the
+// destination directory.
+func walkPages(dir string) gitweb.WalkFunc {
+	return func(name string, page *gitweb.RepoPage) error {
+		if *historySize > 0 {
+			err := writeHistory(dir, name, page)
+			if err != nil {
+				return err
+			}
+		}
+		err := writeRaw(dir, name, page)
+		if err != nil {
+			return err
+		}
+
+		name = filepath.Join(dir, name)
+		if *verbose {
+			fmt.Println(name)
+		}
+
+		dest := filepath.Join(*destination, name+".html")
+		if page == nil { // file was removed
+			err := os.Remove(dest)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+
+			// In case name refers to a (now empty) directory:
+			os.Remove(filepath.Join(*destination, name))
+
+			return removeBlame(name)
+		} else if isIndexPage(page) {
+			dest = filepath.Join(*destination, dir, "index.html")
+
+			// The index is rebuild if the commits changed, since
+			// commits are also listed in the feed rebuild it too.
+			err := writePage(filepath.Join(*destination, dir, feedFile), "atom.tmpl", page)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = writePage(dest, "base.tmpl", page)
+		if err != nil || page.CurrentFile.IsDir() {
+			return err
+		}
+
+		// Blame pages of files which are now binary or too large
+		// are removed, since they are no longer linked.
+		path, err := blamePath(page)
+		if err != nil {
+			return err
+		} else if path == "" {
+			return removeBlame(name)
+		}
+		return writePage(filepath.Join(*destination, name+blameSuffix), "blame.tmpl", blamePage{page})
+	}
+}
+
+// Removes the blame page for the page of the given name, if any.
+func removeBlame(name string) error {
+	err := os.Remove(filepath.Join(*destination, name+blameSuffix))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Returns a LogFunc which writes log pages to the given subdirectory of the
+// destination directory.
+func walkLog(dir string) gitweb.LogFunc {
+	return func(number int, page *gitweb.LogPage) error {
+		name := filepath.Join(dir, logDir, strconv.Itoa(number))
+		if *verbose {
+			fmt.Println(name)
+		}
+
+		dest := filepath.Join(*destination, name+".html")
+		if page == nil { // page was removed
+			err := os.Remove(dest)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+
+		return writePage(dest, "log.tmpl", page)
+	}
+}
+
+// Writes the history page for the page of the given name to the history
+// subdirectory of the given subdirectory of the destination directory.
+func writeHistory(dir, name string, page *gitweb.RepoPage) error {
+	if name == "." {
+		name = "index"
+	}
+	name = filepath.Join(dir, historyDir, name)
 	if *verbose {
 		fmt.Println(name)
 	}
@@ -59,10 +240,95 @@ func walkPages(name string, page *gitweb.RepoPage) error {
 		os.Remove(filepath.Join(*destination, name))
 
 		return nil
-	} else if isIndexPage(page) {
-		dest = filepath.Join(*destination, "index.html")
 	}
 
+	return writePage(dest, "history.tmpl", historyPage{page})
+}
+
+// Reports whether the raw content of the file of the given page is written.
+// No raw content is written for symbolic links and submodules. Unless
+// requested, the raw content is only written for images. SVG images are
+// never written, since scripts contained in them are executed if such an
+// image is opened directly, they are embedded in the page instead.
+func hasRaw(page *gitweb.RepoPage) (bool, error) {
+	file := page.CurrentFile
+	if file.IsDir() || file.IsSymlink() || file.IsSubmodule() {
+		return false, nil
+	}
+
+	img, err := page.Image()
+	if err != nil {
+		return false, err
+	} else if img != nil {
+		return !img.IsSVG(), nil
+	}
+	return *raw && !isSVGName(file.Path), nil
+}
+
+// Writes the raw content of the file of the given page to the raw
+// subdirectory of the given subdirectory of the destination directory,
+// if any, see hasRaw.
+func writeRaw(dir, name string, page *gitweb.RepoPage) error {
+	dest := filepath.Join(*destination, dir, rawDir, name)
+	if page == nil { // file was removed
+		err := os.Remove(dest)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	} else if page.CurrentFile.IsDir() {
+		return nil
+	}
+
+	write, err := hasRaw(page)
+	if err != nil {
+		return err
+	} else if !write {
+		// The file may have been a regular file or an image previously.
+		fi, err := os.Lstat(dest)
+		if err == nil && fi.Mode().IsRegular() {
+			return os.Remove(dest)
+		}
+		return nil
+	}
+
+	file, err := page.Blob()
+	if err != nil {
+		return err
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, reader)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func walkCommits(page *gitweb.CommitPage) error {
+	name := filepath.Join(commitDir, page.Commit.Hash.String())
+	if *verbose {
+		fmt.Println(name)
+	}
+
+	dest := filepath.Join(*destination, name+".html")
+	return writePage(dest, "commit.tmpl", page)
+}
+
+func writePage(dest, name string, data any) error {
 	err := os.MkdirAll(filepath.Dir(dest), 0755)
 	if err != nil {
 		return err
@@ -73,7 +339,7 @@ func walkPages(name string, page *gitweb.RepoPage) error {
 	}
 	defer file.Close()
 
-	err = tmpl.Execute(file, page)
+	err = tmpl.ExecuteTemplate(file, name, data)
 	if err != nil {
 		return err
 	}
@@ -97,6 +363,27 @@ func buildHTML() (*template.Template, error) {
 		"relIndex":     relIndex,
 		"isIndexPage":  isIndexPage,
 		"renderReadme": renderReadme,
+		"renderBlob":   renderBlob,
+		"diffLines":    diffLines,
+		"commitRow":    newCommitRow,
+		"relRoot":      relRoot,
+		"refPath":      refPath,
+		"archives":     archives,
+		"snapshots":    snapshots,
+		"archiveExt":   archiveExt,
+		"feedEntries":  func() uint { return *feedEntries },
+		"formatSize":   formatSize,
+		"lastLogPage":  lastLogPage,
+		"historySize":  func() uint { return *historySize },
+		"historyPath":  historyPath,
+		"blamePath":    blamePath,
+		"highlight":    highlightBlob,
+		"rawPath":      rawPath,
+		"imagePath":    imagePath,
+		"imageSize":    imageSize,
+		"shortHash":    shortHash,
+		"linkFrom":     linkFrom,
+		"pagePath":     pagePath,
 	}
 	tmpl = tmpl.Funcs(funcMap)
 
@@ -114,14 +401,66 @@ func generate(repo *gitweb.Repo) error {
 	if err != nil {
 		return err
 	}
-	err = repo.Walk(walkPages)
+	for _, ref := range repo.Refs() {
+		dir := filepath.FromSlash(refPath(ref))
+		err = ref.Walk(walkPages(dir))
+		if err != nil {
+			return err
+		}
+
+		// History pages also list the tags of commits.
+		if *historySize > 0 {
+			err = ref.WalkRetagged(int(*historySize), func(name string, page *gitweb.RepoPage) error {
+				return writeHistory(dir, name, page)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if *logSize > 0 {
+			err = ref.WalkLog(int(*logSize), walkLog(dir))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = repo.WalkCommits(walkCommits)
+	if err != nil {
+		return err
+	}
+
+	created, err := generateArchives(repo)
 	if err != nil {
 		return err
 	}
 
+	if repo.TagsChanged() || created {
+		name := filepath.Join(tagsDir, "index")
+		if *verbose {
+			fmt.Println(name)
+		}
+
+		err = writePage(filepath.Join(*destination, name+".html"), "tags.tmpl", repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if repo.TagsChanged() {
+		if *verbose {
+			fmt.Println(tagsFeedFile)
+		}
+
+		err = writePage(filepath.Join(*destination, tagsFeedFile), "atom-tags.tmpl", repo)
+		if err != nil {
+			return err
+		}
+	}
+
 	cssPath := filepath.Join(*destination, "style.css")
 	_, err = os.Stat(cssPath)
-	if *force || errors.Is(err, os.ErrNotExist) {
+	if *force || repo.Outdated() || errors.Is(err, os.ErrNotExist) {
 		err = css.Create(cssPath)
 		if err != nil {
 			return err
@@ -132,6 +471,10 @@ func generate(repo *gitweb.Repo) error {
 }
 
 func main() {
+	flag.Var(&branches, "b", "additional branch to generate HTML files for")
+	flag.Var(&gitURLs, "u", "clone `URL` for the Git repository, optionally preceded by a label")
+	flag.Var(&blobMaxSize, "m", "maximum `size` of the displayed content of each file")
+	flag.Var(&treeMaxSize, "t", "maximum `size` of the tree for which file content is displayed")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -140,31 +483,71 @@ func main() {
 		usage()
 	}
 
-	gitURL, err := url.Parse(*gitURL)
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	path := flag.Arg(0)
 	statePath := filepath.Join(*destination, stateFile)
+	legacyPath := filepath.Join(*destination, legacyStateFile)
+	statsPath := filepath.Join(*destination, statsFile)
 
-	repo, err := gitweb.NewRepo(path, gitURL, *commits)
+	repo, err := gitweb.NewRepo(path, gitURLs, *commits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = repo.LoadKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Limits passed as flags take precedence over the configuration,
+	// they are applied before branches copy the configuration.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "m":
+			repo.Conf.BlobMaxSize = int64(blobMaxSize)
+		case "n":
+			repo.Conf.BlobMaxLines = int(*blobMaxLines)
+		case "t":
+			repo.Conf.TreeMaxSize = int64(treeMaxSize)
+		}
+	})
+	for _, branch := range append(branches, repo.Conf.Refs...) {
+		_, err = repo.AddBranch(branch)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	build, err := currentBuild(repo)
 	if err != nil {
 		log.Fatal(err)
 	}
 	if !*force {
-		err = repo.ReadState(statePath)
+		err = repo.ReadState(statePath, build)
+		if errors.Is(err, os.ErrNotExist) {
+			err = repo.ReadLegacyState(legacyPath)
+		}
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			log.Fatal(err)
 		}
 	}
+	// The stats of a commit never change, hence they are also
+	// retained if all files are regenerated.
+	err = repo.ReadStats(statsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
 
 	err = generate(repo)
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = repo.WriteState(statePath)
+	err = repo.WriteState(statePath, build)
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = repo.WriteStats(statsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.Remove(legacyPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
 }
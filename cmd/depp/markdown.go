@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"git.8pit.net/depp/gitweb"
+)
+
+// Context key for the page on which the Markdown document is rendered.
+var pageKey = parser.NewContextKey()
+
+// linkTransformer rewrites relative links in a Markdown document to point
+// to the generated page of the linked file, and relative images to point
+// to the raw copy of the image.
+type linkTransformer struct{}
+
+func (t linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	page := pc.Get(pageKey).(*gitweb.RepoPage)
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Link:
+			n.Destination = rewriteLink(page, n.Destination)
+		case *ast.Image:
+			n.Destination = rewriteImage(page, n.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// Resolves a link relative to the directory of the given page or the
+// directory containing the file of the page. Returns false for links which
+// are absolute or point outside of the repository.
+func resolveLink(page *gitweb.RepoPage, dest []byte) (string, *url.URL, bool) {
+	u, err := url.Parse(string(dest))
+	if err != nil || u.IsAbs() || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return "", nil, false
+	}
+
+	dir := page.CurrentFile.Path
+	if !page.CurrentFile.IsDir() {
+		dir = path.Dir(dir)
+	}
+
+	fp := path.Join(dir, u.Path)
+	if fp == ".." || strings.HasPrefix(fp, "../") {
+		return "", nil, false
+	}
+	return fp, u, true
+}
+
+// Appends the query and fragment of the given URL to a link.
+func appendQuery(link string, u *url.URL) []byte {
+	if u.RawQuery != "" {
+		link += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		link += "#" + u.EscapedFragment()
+	}
+	return []byte(link)
+}
+
+// Rewrites a relative link to the generated page of the linked file. Links
+// which are absolute or point outside of the repository are returned
+// unmodified.
+func rewriteLink(page *gitweb.RepoPage, dest []byte) []byte {
+	fp, u, ok := resolveLink(page, dest)
+	if !ok {
+		return dest
+	}
+
+	// Pages for directories are located next to the directory, hence
+	// the relative path to the pages of both is the same.
+	link := relIndex(&page.CurrentFile)
+	if fp == "." {
+		link += "index.html"
+	} else {
+		link += fp + ".html"
+	}
+
+	return appendQuery(link, u)
+}
+
+// Rewrites a relative image to the copy in the raw subdirectory. No copy
+// exists for SVG images, such images are returned unmodified, as are
+// images outside of the repository.
+func rewriteImage(page *gitweb.RepoPage, dest []byte) []byte {
+	fp, u, ok := resolveLink(page, dest)
+	if !ok || fp == "." || isSVGName(fp) {
+		return dest
+	}
+
+	return appendQuery(relIndex(&page.CurrentFile)+rawDir+"/"+fp, u)
+}
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(util.Prioritized(linkTransformer{}, 100)),
+	),
+)
+
+// Renders the given Markdown document of a page as HTML.
+func renderMarkdown(page *gitweb.RepoPage, source string) (template.HTML, error) {
+	ctx := parser.NewContext()
+	ctx.Set(pageKey, page)
+
+	var buf bytes.Buffer
+	err := markdown.Convert([]byte(source), &buf, parser.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"git.8pit.net/depp/gitweb"
 )
@@ -32,30 +36,113 @@ func runWithInput(cmd *exec.Cmd, input string) (string, error) {
 	return string(out), nil
 }
 
-func renderReadme(repo *gitweb.RepoPage) (template.HTML, error) {
-	readme, err := repo.Readme()
+// Extensions of markup files which are rendered on their blob page. Files
+// other than Markdown files are only rendered if a render script exists.
+var markupExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".rst":      true,
+	".adoc":     true,
+	".asciidoc": true,
+	".org":      true,
+	".textile":  true,
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// Returns the render script of the repository, or an empty string if the
+// repository does not contain a render script.
+func renderer(repo *gitweb.Repo) (string, error) {
+	fp := filepath.Join(repo.Path, renderScript)
+	script, err := exec.LookPath(fp)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, exec.ErrNotFound) {
+		return "", nil
+	}
+	return script, err
+}
+
+// Renders the given markup file of a page using the given render script,
+// or the built-in Markdown renderer if there is no render script. The
+// render script receives the file name via the DEPP_FILENAME environment
+// variable.
+func renderMarkup(page *gitweb.RepoPage, script, name, contents string) (template.HTML, error) {
+	if script == "" {
+		return renderMarkdown(page, contents)
+	}
+
+	cmd := exec.Command(script)
+	cmd.Env = append(os.Environ(), "DEPP_FILENAME="+name)
+	out, err := runWithInput(cmd, contents)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(out), nil
+}
+
+// Renders an SVG image as an img element with a data URI. SVG images are
+// not written to the destination directory, as they may contain scripts
+// which would be executed if the image is opened directly.
+func renderSVG(page *gitweb.RepoPage, img *gitweb.Image, contents string) template.HTML {
+	src := "data:" + img.Type + ";base64," + base64.StdEncoding.EncodeToString([]byte(contents))
+	return template.HTML(fmt.Sprintf(`<p class="image"><img src="%s" alt="%s"%s></p>`,
+		src, template.HTMLEscapeString(page.CurrentFile.Name()), imageSize(img)))
+}
+
+func renderReadme(page *gitweb.RepoPage) (template.HTML, error) {
+	readme, err := page.Readme()
 	if errors.Is(err, os.ErrNotExist) {
 		return "", nil
 	} else if err != nil {
 		return "", err
 	}
-
-	fp := filepath.Join(repo.Path, renderScript)
-	renderer, err := exec.LookPath(fp)
+	name, err := page.ReadmeName()
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) || errors.Is(err, exec.ErrNotFound) {
-			escaped := template.HTMLEscapeString(readme)
-			return template.HTML(`<pre class="raw">` + escaped + "</pre>"), nil
-		}
+		return "", err
+	}
 
+	script, err := renderer(page.Repo)
+	if err != nil {
 		return "", err
+	} else if script == "" && !isMarkdown(name) {
+		escaped := template.HTMLEscapeString(readme)
+		return template.HTML(`<pre class="raw">` + escaped + "</pre>"), nil
 	}
 
-	cmd := exec.Command(renderer)
-	out, err := runWithInput(cmd, readme)
+	return renderMarkup(page, script, name, readme)
+}
+
+// Renders the file of a blob page if it is a markup file or an SVG image,
+// an empty string is returned for all other files.
+func renderBlob(page *gitweb.RepoPage) (template.HTML, error) {
+	img, err := page.Image()
 	if err != nil {
 		return "", err
 	}
 
-	return template.HTML(out), nil
+	name := page.CurrentFile.Name()
+	isSVG := img != nil && img.IsSVG()
+	if !isSVG && !markupExts[strings.ToLower(path.Ext(name))] {
+		return "", nil
+	}
+
+	// Files exceeding the limits are not rendered, as a truncated
+	// file cannot be rendered correctly, only a preview is displayed.
+	preview, err := page.Preview()
+	if err != nil || preview.Truncated || preview.Omitted {
+		return "", err
+	}
+	if isSVG {
+		return renderSVG(page, img, preview.Contents), nil
+	}
+
+	script, err := renderer(page.Repo)
+	if err != nil || (script == "" && !isMarkdown(name)) {
+		return "", err
+	}
+
+	return renderMarkup(page, script, name, preview.Contents)
 }
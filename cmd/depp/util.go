@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
+	"net/url"
+	"path"
 	"strings"
 
 	"git.8pit.net/depp/gitweb"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func summarize(msg string) string {
@@ -77,11 +82,203 @@ func padNumber(maxnum int, curnum int) template.HTML {
 	return template.HTML(buf.String())
 }
 
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+// Splits a unified diff into lines and classifies each line.
+func diffLines(unified string) []diffLine {
+	lines := getLines(unified)
+	result := make([]diffLine, len(lines))
+
+	header := true
+	for i, line := range lines {
+		class := "context"
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			header = false
+			class = "hunk"
+		case header:
+			class = "meta"
+		case strings.HasPrefix(line, "+"):
+			class = "add"
+		case strings.HasPrefix(line, "-"):
+			class = "del"
+		}
+
+		result[i] = diffLine{class, line}
+	}
+
+	return result
+}
+
+// A commit in a table of commits, along with the page containing the table.
+type commitRow struct {
+	Page   any
+	Commit *object.Commit
+}
+
+func newCommitRow(page any, commit *object.Commit) commitRow {
+	return commitRow{page, commit}
+}
+
+// Formats a size in bytes in a human readable way, using binary prefixes.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// Returns the abbreviated form of a hash, as used by git by default.
+func shortHash(h plumbing.Hash) string {
+	return h.String()[0:7]
+}
+
+// Returns the given link relative to the directory root, which must be the
+// relative path to the destination directory. Absolute links are returned
+// unmodified.
+func linkFrom(root, link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.IsAbs() || strings.HasPrefix(link, "/") {
+		return link
+	}
+	return root + link
+}
+
 func relIndex(file *gitweb.RepoFile) string {
 	elems := file.PathElements()
 	return getRelPath(len(elems) - 1)
 }
 
+// Returns the relative path from the given page to the destination directory.
+func relRoot(page any) string {
+	switch p := page.(type) {
+	case *gitweb.RepoPage:
+		depth := len(p.CurrentFile.PathElements()) - 1
+		if dir := refPath(p.Repo); dir != "" {
+			depth += strings.Count(dir, "/")
+		}
+		return getRelPath(depth)
+	case blamePage:
+		return relRoot(p.RepoPage)
+	case historyPage:
+		// History pages are located in a subdirectory of the branch.
+		return relRoot(p.RepoPage) + "../"
+	case *gitweb.LogPage:
+		return getRelPath(1 + strings.Count(refPath(p.Repo), "/"))
+	case *gitweb.CommitPage:
+		return getRelPath(1)
+	case *gitweb.Repo:
+		// Pages for the entire repository are located in a subdirectory.
+		return getRelPath(1)
+	default:
+		panic("unknown page type")
+	}
+}
+
+// Returns the slash separated directory, relative to the destination
+// directory, containing the pages for the given branch.
+func refPath(repo *gitweb.Repo) string {
+	if repo.Branch == "" {
+		return ""
+	}
+	return path.Join(branchDir, repo.Branch) + "/"
+}
+
+// Returns the slash separated path, relative to the destination directory,
+// of the page for the given slash separated path in the tree of a branch.
+func pagePath(repo *gitweb.Repo, fp string) string {
+	if fp == "" {
+		return refPath(repo) + "index.html"
+	}
+	return refPath(repo) + fp + ".html"
+}
+
+// Returns the number of the log page containing the newest commits for
+// the given amount of commits, zero if no log pages are generated.
+func lastLogPage(commits uint) uint {
+	if *logSize == 0 {
+		return 0
+	}
+	return (commits + *logSize - 1) / *logSize
+}
+
+// Returns the relative path from the page of the given file to its history
+// page, or an empty string if no history pages are generated.
+func historyPath(file *gitweb.RepoFile) string {
+	if *historySize == 0 {
+		return ""
+	} else if file.Path == "" {
+		return historyDir + "/index.html"
+	}
+	return relIndex(file) + historyDir + "/" + file.Path + ".html"
+}
+
+// Returns the relative path from the page of a file to its raw content, or
+// an empty string if the raw content of the file is not written.
+func rawPath(page any) (string, error) {
+	var p *gitweb.RepoPage
+	switch page := page.(type) {
+	case *gitweb.RepoPage:
+		p = page
+	case blamePage:
+		p = page.RepoPage
+	}
+	if !*raw || p == nil {
+		return "", nil
+	}
+
+	ok, err := hasRaw(p)
+	if err != nil || !ok {
+		return "", err
+	}
+	return relIndex(&p.CurrentFile) + rawDir + "/" + p.CurrentFile.Path, nil
+}
+
+// Reports whether the given file name has the extension of SVG images.
+func isSVGName(fp string) bool {
+	ext := strings.ToLower(path.Ext(fp))
+	return ext == ".svg" || ext == ".svgz"
+}
+
+// Returns the relative path from the page of a file to the copy of the
+// file, if the file is an image which is written to the raw subdirectory.
+func imagePath(file *gitweb.RepoFile) string {
+	return relIndex(file) + rawDir + "/" + file.Path
+}
+
+// Returns the width and height attributes for the given image.
+func imageSize(img *gitweb.Image) template.HTMLAttr {
+	if img.Width == 0 || img.Height == 0 {
+		return ""
+	}
+	return template.HTMLAttr(fmt.Sprintf(` width="%d" height="%d"`, img.Width, img.Height))
+}
+
+// Returns the relative path from the page of a file to its blame page, or
+// an empty string if no blame page is generated for the file.
+func blamePath(page any) (string, error) {
+	p, ok := page.(*gitweb.RepoPage)
+	if !ok || !(*blame || p.Conf.Blame) {
+		return "", nil // blame pages do not link to themselves
+	}
+
+	ok, err := p.CanBlame(p.Conf.BlameMaxSize)
+	if err != nil || !ok {
+		return "", err
+	}
+	return p.CurrentFile.Name() + blameSuffix, nil
+}
+
 func isIndexPage(page *gitweb.RepoPage) bool {
 	return page.CurrentFile.Path == ""
 }
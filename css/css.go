@@ -3,12 +3,18 @@ package css
 import (
 	"embed"
 	"html/template"
+	"io/fs"
 	"os"
 )
 
 //go:embed tmpl
 var templates embed.FS
 
+// Templates returns the file system containing the stylesheet templates.
+func Templates() fs.FS {
+	return templates
+}
+
 func Create(path string) error {
 	const name = "base.tmpl"
 	stylesheet := template.New(name)
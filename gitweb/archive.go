@@ -0,0 +1,198 @@
+package gitweb
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// File name of the git attributes file.
+	attrFn = ".gitattributes"
+
+	// Name of the attribute used to exclude files from archives.
+	exportIgnore = "export-ignore"
+)
+
+// archiveFunc is called for each file which should be included in an archive.
+type archiveFunc func(fp string, mode fs.FileMode, blob *object.Blob) error
+
+// Calls fn for each file in the tree of the given commit, files with the
+// export-ignore attribute and submodules are skipped.
+func walkArchive(commit *object.Commit, fn archiveFunc) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	return walkArchiveTree(tree, nil, nil, fn)
+}
+
+func walkArchiveTree(tree *object.Tree, dir []string, stack []gitattributes.MatchAttribute, fn archiveFunc) error {
+	if file, err := tree.File(attrFn); err == nil {
+		reader, err := file.Reader()
+		if err != nil {
+			return err
+		}
+		attrs, err := gitattributes.ReadAttributes(reader, dir, len(dir) == 0)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+
+		// Copy the stack to not modify the stack of the parent directory.
+		stack = append(stack[:len(stack):len(stack)], attrs...)
+	} else if err != object.ErrFileNotFound {
+		return err
+	}
+	matcher := gitattributes.NewMatcher(stack)
+
+	for _, entry := range tree.Entries {
+		elems := append(dir[:len(dir):len(dir)], entry.Name)
+		results, _ := matcher.Match(elems, []string{exportIgnore})
+		if attr, ok := results[exportIgnore]; ok && attr.IsSet() {
+			continue
+		}
+
+		fp := strings.Join(elems, "/")
+		switch entry.Mode {
+		case filemode.Dir:
+			subtree, err := tree.Tree(entry.Name)
+			if err != nil {
+				return err
+			}
+			err = walkArchiveTree(subtree, elems, stack, fn)
+			if err != nil {
+				return err
+			}
+		case filemode.Submodule:
+			continue
+		default:
+			mode, err := entry.Mode.ToOSFileMode()
+			if err != nil {
+				return err
+			}
+			blob, err := tree.TreeEntryFile(&entry)
+			if err != nil {
+				return err
+			}
+
+			err = fn(fp, mode, &blob.Blob)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the permission bits used for a file of the given mode in archives.
+func archivePerm(mode fs.FileMode) fs.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// WriteTarGz writes a gzip compressed tar archive of the tree of the given
+// commit to w. All files in the archive are prefixed with the given prefix.
+func WriteTarGz(w io.Writer, commit *object.Commit, prefix string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	modTime := commit.Committer.When
+	err := walkArchive(commit, func(fp string, mode fs.FileMode, blob *object.Blob) error {
+		hdr := &tar.Header{
+			Name:    path.Join(prefix, fp),
+			Mode:    int64(archivePerm(mode)),
+			ModTime: modTime,
+			Format:  tar.FormatPAX,
+		}
+
+		reader, err := blob.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		if mode&fs.ModeSymlink != 0 {
+			target, err := io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = string(target)
+			hdr.Mode = 0777
+			return tw.WriteHeader(hdr)
+		}
+
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = blob.Size
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// WriteZip writes a zip archive of the tree of the given commit to w.
+// All files in the archive are prefixed with the given prefix.
+func WriteZip(w io.Writer, commit *object.Commit, prefix string) error {
+	zw := zip.NewWriter(w)
+
+	modTime := commit.Committer.When.In(time.UTC)
+	err := walkArchive(commit, func(fp string, mode fs.FileMode, blob *object.Blob) error {
+		hdr := &zip.FileHeader{
+			Name:     path.Join(prefix, fp),
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		if mode&fs.ModeSymlink != 0 {
+			hdr.SetMode(fs.ModeSymlink | 0777)
+		} else {
+			hdr.SetMode(archivePerm(mode))
+		}
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		reader, err := blob.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		_, err = io.Copy(fw, reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,93 @@
+package gitweb
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// BlameLine represents a single line of a blamed file.
+type BlameLine struct {
+	Number int // Starting at 1
+	Text   string
+}
+
+// BlameHunk represents consecutive lines last changed by the same commit.
+type BlameHunk struct {
+	Commit *object.Commit
+	Lines  []BlameLine
+}
+
+// Blame represents the commit which last changed each line of a file.
+type Blame struct {
+	Hunks []*BlameHunk
+	Lines int // Total amount of lines
+}
+
+// Blame returns the commit which last changed each line of the current
+// file, consecutive lines changed by the same commit are grouped.
+func (r *RepoPage) Blame() (*Blame, error) {
+	if r.CurrentFile.IsDir() || r.CurrentFile.IsSubmodule() || r.CurrentFile.IsSymlink() {
+		return nil, ExpectedRegular
+	}
+
+	result, err := git.Blame(r.curCommit, r.CurrentFile.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	blame := &Blame{Lines: len(result.Lines)}
+	commits := make(map[plumbing.Hash]*object.Commit)
+
+	var hunk *BlameHunk
+	for i, line := range result.Lines {
+		if hunk == nil || hunk.Commit.Hash != line.Hash {
+			commit, ok := commits[line.Hash]
+			if !ok {
+				commit, err = r.git.CommitObject(line.Hash)
+				if err != nil {
+					return nil, err
+				}
+				commits[line.Hash] = commit
+			}
+
+			hunk = &BlameHunk{Commit: commit}
+			blame.Hunks = append(blame.Hunks, hunk)
+		}
+
+		text := strings.TrimRight(line.Text, "\r")
+		hunk.Lines = append(hunk.Lines, BlameLine{i + 1, text})
+	}
+
+	return blame, nil
+}
+
+// CanBlame reports whether a blame of the current file can be generated,
+// i.e. whether it is a text file of at most maxSize bytes. No blame is
+// generated if the tree exceeds the configured size limit.
+func (r *RepoPage) CanBlame(maxSize int64) (bool, error) {
+	if r.CurrentFile.IsDir() || r.CurrentFile.IsSubmodule() || r.CurrentFile.IsSymlink() {
+		return false, nil
+	}
+
+	omit, err := r.treeTooLarge()
+	if err != nil || omit {
+		return false, err
+	}
+
+	file, err := r.Blob()
+	if err != nil {
+		return false, err
+	}
+	if file.Size > maxSize {
+		return false, nil
+	}
+
+	binary, err := file.IsBinary()
+	if err != nil {
+		return false, err
+	}
+	return !binary, nil
+}
@@ -0,0 +1,140 @@
+package gitweb
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Amount of context lines included in unified diffs.
+const diffContext = 3
+
+// CommitPage represents information for a single commit.
+type CommitPage struct {
+	*Repo
+
+	Commit *object.Commit
+}
+
+// FileDiff represents the changes of a single file in a commit.
+type FileDiff struct {
+	From string // Empty if the file was created
+	To   string // Empty if the file was removed
+
+	Binary   bool
+	Addition int
+	Deletion int
+
+	// Unified diff of the file, including the diff header.
+	Unified string
+}
+
+type CommitFunc func(*CommitPage) error
+
+// singlePatch implements diff.Patch for a single diff.FilePatch.
+type singlePatch struct {
+	fp diff.FilePatch
+}
+
+func (p singlePatch) FilePatches() []diff.FilePatch {
+	return []diff.FilePatch{p.fp}
+}
+
+func (p singlePatch) Message() string {
+	return ""
+}
+
+func (f *FileDiff) Name() string {
+	if f.To == "" {
+		return f.From
+	}
+	return f.To
+}
+
+// Parents returns all parent commits of the commit.
+func (c *CommitPage) Parents() ([]*object.Commit, error) {
+	var parents []*object.Commit
+
+	err := c.Commit.Parents().ForEach(func(p *object.Commit) error {
+		parents = append(parents, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parents, nil
+}
+
+// Returns the patch for the changes introduced by the given commit. Merge
+// commits are compared against their first parent, root commits against
+// the empty tree.
+func commitPatch(c *object.Commit) (*object.Patch, error) {
+	to, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	from := &object.Tree{}
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		from, err = parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return from.Patch(to)
+}
+
+// Returns the amount of added and deleted lines of a file patch.
+func countChanges(fp diff.FilePatch) (additions, deletions int) {
+	for _, chunk := range fp.Chunks() {
+		switch chunk.Type() {
+		case diff.Add:
+			additions += countLines(chunk.Content())
+		case diff.Delete:
+			deletions += countLines(chunk.Content())
+		}
+	}
+	return additions, deletions
+}
+
+// Diff returns the changes introduced by the commit. Merge commits are
+// compared against their first parent, root commits against the empty tree.
+func (c *CommitPage) Diff() ([]FileDiff, error) {
+	patch, err := commitPatch(c.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []FileDiff
+	for _, fp := range patch.FilePatches() {
+		var fd FileDiff
+
+		from, to := fp.Files()
+		if from != nil {
+			fd.From = from.Path()
+		}
+		if to != nil {
+			fd.To = to.Path()
+		}
+		fd.Binary = fp.IsBinary()
+		fd.Addition, fd.Deletion = countChanges(fp)
+
+		buf := new(strings.Builder)
+		err = diff.NewUnifiedEncoder(buf, diffContext).Encode(singlePatch{fp})
+		if err != nil {
+			return nil, err
+		}
+		fd.Unified = buf.String()
+
+		diffs = append(diffs, fd)
+	}
+
+	return diffs, nil
+}
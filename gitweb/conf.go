@@ -1,7 +1,13 @@
 package gitweb
 
 import (
+	"errors"
+	"fmt"
 	"html/template"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
 )
@@ -9,10 +15,179 @@ import (
 const (
 	// Name of the depp-specific Git configuration section.
 	confSec = "depp"
+
+	// Default maximum size of files for which a blame is generated.
+	defBlameMaxSize = 128 * 1024
+
+	// Default limits after which only a preview of a file is displayed.
+	defBlobMaxSize  = 1024 * 1024
+	defBlobMaxLines = 20000
 )
 
+// Default README patterns, ordered by preference.
+var defReadmes = []string{
+	"README.md",
+	"README.markdown",
+	"README.rst",
+	"README.adoc",
+	"README.asciidoc",
+	"README.org",
+	"README.textile",
+	"README.txt",
+	"README",
+}
+
+// CloneURL represents a URL the repository can be cloned from.
+type CloneURL struct {
+	Label string // Optional, e.g. the protocol
+	URL   string
+}
+
+// ParseCloneURL parses a clone URL which is optionally preceded by a
+// label, separated by whitespace. The URL may contain a %s placeholder
+// for the name of the repository. Besides URLs, the scp-like syntax
+// supported by git, e.g. git@example.org:repo.git, is accepted.
+func ParseCloneURL(value string) (CloneURL, error) {
+	var cloneURL CloneURL
+	fields := strings.Fields(value)
+	switch len(fields) {
+	case 1:
+		cloneURL = CloneURL{URL: fields[0]}
+	case 2:
+		cloneURL = CloneURL{Label: fields[0], URL: fields[1]}
+	default:
+		return CloneURL{}, fmt.Errorf("invalid clone URL %q", value)
+	}
+
+	// The placeholder is not a valid escape sequence.
+	rawURL := cloneURL.expand("repo").URL
+	host, _, found := strings.Cut(rawURL, ":")
+	if found && !strings.Contains(rawURL, "://") && !strings.Contains(host, "/") {
+		if host == "" {
+			return CloneURL{}, fmt.Errorf("invalid clone URL %q", value)
+		}
+		return cloneURL, nil // scp-like syntax
+	}
+	_, err := url.Parse(rawURL)
+	if err != nil {
+		return CloneURL{}, fmt.Errorf("invalid clone URL %q: %w", value, err)
+	}
+
+	return cloneURL, nil
+}
+
+// CloneURLList is a flag.Value which parses each passed value using
+// ParseCloneURL, it can be passed multiple times.
+type CloneURLList []CloneURL
+
+func (l *CloneURLList) String() string {
+	var urls []string
+	for _, cloneURL := range *l {
+		urls = append(urls, cloneURL.URL)
+	}
+	return strings.Join(urls, ",")
+}
+
+func (l *CloneURLList) Set(value string) error {
+	cloneURL, err := ParseCloneURL(value)
+	if err != nil {
+		return err
+	}
+
+	*l = append(*l, cloneURL)
+	return nil
+}
+
+// Replaces the %s placeholder in the URL with the given repository name.
+func (c CloneURL) expand(name string) CloneURL {
+	c.URL = strings.ReplaceAll(c.URL, "%s", name)
+	return c
+}
+
 type Config struct {
 	HeaderExtra template.HTML
+
+	// Metadata displayed in the header, all of them are optional.
+	Owner       string
+	Homepage    string
+	IssueURL    string
+	MailingList string
+
+	// Clone URLs, which take precedence over those passed to NewRepo.
+	CloneURLs []CloneURL
+
+	// Overrides for the title of the repository and the branch
+	// which is treated as the default branch instead of HEAD.
+	Title         string
+	DefaultBranch string
+
+	// Additional branches to render, besides the default branch.
+	Refs []string
+
+	// Patterns for names of README files, ordered by preference.
+	Readmes []string
+
+	// Maps prefixes of submodule URLs to link prefixes.
+	SubmoduleLinks map[string]string
+
+	// Paths of an OpenPGP keyring and an SSH allowed signers file used to
+	// verify signatures of commits and tags, relative to the repository.
+	Keyring        string
+	AllowedSigners string
+
+	// Only follow the first parent of merge commits in the commit log.
+	FirstParent bool
+
+	// Generate blame pages for text files of at most BlameMaxSize bytes.
+	Blame        bool
+	BlameMaxSize int64
+
+	// Only display the first BlobMaxSize bytes and the first BlobMaxLines
+	// lines of files. If the total size of all files in the tree exceeds
+	// TreeMaxSize, the content of files is not displayed at all. Zero
+	// disables the respective limit.
+	BlobMaxSize  int64
+	BlobMaxLines int
+	TreeMaxSize  int64
+}
+
+// Returns the configuration used if the repository does not configure depp.
+func defaultConfig() Config {
+	return Config{
+		Readmes:      defReadmes,
+		BlameMaxSize: defBlameMaxSize,
+		BlobMaxSize:  defBlobMaxSize,
+		BlobMaxLines: defBlobMaxLines,
+	}
+}
+
+// ParseSize parses a size in bytes with an optional k, m, or g suffix, like git.
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, errors.New("empty size")
+	}
+
+	var unit int64 = 1
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		unit = 1024
+	case "m":
+		unit = 1024 * 1024
+	case "g":
+		unit = 1024 * 1024 * 1024
+	}
+	if unit != 1 {
+		s = s[0 : len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	} else if n < 0 {
+		return 0, fmt.Errorf("negative size %q", s)
+	}
+
+	return n * unit, nil
 }
 
 func loadConfig(repo *git.Repository) (Config, error) {
@@ -23,12 +198,89 @@ func loadConfig(repo *git.Repository) (Config, error) {
 
 	raw := c.Raw
 	if !raw.HasSection(confSec) {
-		return Config{}, nil
+		return defaultConfig(), nil
 	}
 
 	sec := raw.Section(confSec)
-	cnf := Config{
-		HeaderExtra: template.HTML(sec.Option("extra-head-content")),
+	cnf := defaultConfig()
+	cnf.HeaderExtra = template.HTML(sec.Option("extra-head-content"))
+	cnf.Owner = sec.Option("owner")
+	cnf.Homepage = sec.Option("homepage")
+	cnf.IssueURL = sec.Option("issue-tracker")
+	cnf.MailingList = sec.Option("mailing-list")
+	cnf.Title = sec.Option("title")
+	cnf.DefaultBranch = sec.Option("default-branch")
+	cnf.Keyring = sec.Option("keyring")
+	cnf.AllowedSigners = sec.Option("allowed-signers")
+	if opt := sec.Option("first-parent"); opt != "" {
+		cnf.FirstParent, err = strconv.ParseBool(opt)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid first-parent: %w", err)
+		}
+	}
+	if opt := sec.Option("blame"); opt != "" {
+		cnf.Blame, err = strconv.ParseBool(opt)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid blame: %w", err)
+		}
+	}
+	if opt := sec.Option("blame-max-size"); opt != "" {
+		cnf.BlameMaxSize, err = ParseSize(opt)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid blame-max-size: %w", err)
+		}
+	}
+	if opt := sec.Option("blob-max-size"); opt != "" {
+		cnf.BlobMaxSize, err = ParseSize(opt)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid blob-max-size: %w", err)
+		}
+	}
+	if opt := sec.Option("blob-max-lines"); opt != "" {
+		cnf.BlobMaxLines, err = strconv.Atoi(opt)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid blob-max-lines: %w", err)
+		} else if cnf.BlobMaxLines < 0 {
+			return Config{}, fmt.Errorf("negative blob-max-lines %q", opt)
+		}
+	}
+	if opt := sec.Option("tree-max-size"); opt != "" {
+		cnf.TreeMaxSize, err = ParseSize(opt)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid tree-max-size: %w", err)
+		}
+	}
+	for _, refs := range sec.OptionAll("refs") {
+		cnf.Refs = append(cnf.Refs, strings.Fields(refs)...)
+	}
+	for _, value := range sec.OptionAll("clone-url") {
+		cloneURL, err := ParseCloneURL(value)
+		if err != nil {
+			return Config{}, err
+		}
+		cnf.CloneURLs = append(cnf.CloneURLs, cloneURL)
+	}
+	if readmes := sec.OptionAll("readme"); len(readmes) > 0 {
+		cnf.Readmes = nil
+		for _, patterns := range readmes {
+			for _, pattern := range strings.Fields(patterns) {
+				_, err = path.Match(pattern, "")
+				if err != nil {
+					return Config{}, fmt.Errorf("invalid readme %q: %w", pattern, err)
+				}
+				cnf.Readmes = append(cnf.Readmes, pattern)
+			}
+		}
+	}
+	for _, mapping := range sec.OptionAll("submodule-link") {
+		prefix, link, found := strings.Cut(strings.TrimSpace(mapping), " ")
+		if !found {
+			return Config{}, fmt.Errorf("invalid submodule-link %q", mapping)
+		}
+		if cnf.SubmoduleLinks == nil {
+			cnf.SubmoduleLinks = make(map[string]string)
+		}
+		cnf.SubmoduleLinks[prefix] = strings.TrimSpace(link)
 	}
 
 	return cnf, nil
@@ -5,15 +5,28 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 // RepoFile represents information for a single file/blob.
 type RepoFile struct {
 	mode filemode.FileMode
+	hash plumbing.Hash
 	Path string // Slash separated path
 }
 
+// RepoEntry represents a directory entry with additional metadata.
+type RepoEntry struct {
+	RepoFile
+
+	Size       int64          // Zero for directories, submodules and symlinks
+	LastCommit *object.Commit // Last commit which touched the entry
+	Submodule  *Submodule     // Only set for submodules
+	Symlink    *Symlink       // Only set for symbolic links
+}
+
 func (f *RepoFile) Name() string {
 	return path.Base(f.Path)
 }
@@ -30,6 +43,10 @@ func (f *RepoFile) IsSubmodule() bool {
 	return f.mode == filemode.Submodule
 }
 
+func (f *RepoFile) IsSymlink() bool {
+	return f.mode == filemode.Symlink
+}
+
 func (f *RepoFile) PathElements() []string {
 	return strings.SplitN(f.Path, "/", -1)
 }
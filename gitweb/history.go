@@ -0,0 +1,301 @@
+package gitweb
+
+import (
+	"io"
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// historyFunc is called for each commit with the slash separated paths of
+// all files changed by the commit. Returning storer.ErrStop stops the walk.
+type historyFunc func(c *object.Commit, changed []string) error
+
+// Walks the history of the tip, newest first, in a single pass. Merge
+// commits are skipped, the changes they introduce are attributed to the
+// commits on the merged branches instead. Commits in seen, which may be
+// nil, are not walked.
+func (r *Repo) walkHistory(seen map[plumbing.Hash]bool, fn historyFunc) error {
+	iter := object.NewCommitIterCTime(r.curCommit, seen, nil)
+	defer iter.Close()
+
+	return iter.ForEach(func(c *object.Commit) error {
+		if c.NumParents() > 1 {
+			return nil
+		}
+
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		parentTree := &object.Tree{}
+		if c.NumParents() == 1 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			parentTree, err = parent.Tree()
+			if err != nil {
+				return err
+			}
+		}
+
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+
+		var changed []string
+		for _, change := range changes {
+			if change.From.Name != "" {
+				changed = append(changed, change.From.Name)
+			}
+			if change.To.Name != "" && change.To.Name != change.From.Name {
+				changed = append(changed, change.To.Name)
+			}
+		}
+
+		return fn(c, changed)
+	})
+}
+
+// Returns the set of all slash separated paths in the tree.
+func (r *Repo) treePaths() (map[string]bool, error) {
+	paths := make(map[string]bool)
+
+	walker := object.NewTreeWalker(r.curTree, true, nil)
+	defer walker.Close()
+	for {
+		fp, _, err := walker.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		paths[fp] = true
+	}
+
+	return paths, nil
+}
+
+// Returns the set of all commits reachable from the given commit.
+func ancestors(c *object.Commit) (map[plumbing.Hash]bool, error) {
+	seen := make(map[plumbing.Hash]bool)
+	err := object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return seen, nil
+}
+
+// Attributes the given paths to the last commit which touched them, not
+// walking the commits in seen. Paths which were not touched by any of the
+// walked commits remain in paths.
+func (r *Repo) walkLastCommits(paths map[string]bool, seen map[plumbing.Hash]bool) (map[string]*object.Commit, error) {
+	result := make(map[string]*object.Commit)
+	err := r.walkHistory(seen, func(c *object.Commit, changed []string) error {
+		for _, fp := range changed {
+			// Also attribute the commit to all parent directories.
+			for ; fp != "."; fp = path.Dir(fp) {
+				if _, ok := result[fp]; ok {
+					break // parents have been attributed already
+				} else if paths[fp] {
+					result[fp] = c
+					delete(paths, fp)
+				}
+			}
+		}
+
+		if len(paths) == 0 {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Returns a map of all paths in the tree, including directories, to the
+// last commit which touched the path. If the last commits were recorded
+// in the state file and the tip descends from the recorded tip, only
+// commits which are not reachable from the recorded tip are walked. The map is only computed once.
+func (r *Repo) lastCommits() (map[string]*object.Commit, error) {
+	if r.lastCommitCache != nil {
+		return r.lastCommitCache, nil
+	}
+
+	// The recorded last commits can only be reused if the recorded tip
+	// is still part of the history, e.g. it was not reset or rebased.
+	var seen map[plumbing.Hash]bool
+	if r.prevCommit != nil && r.prevLastCommits != nil {
+		descends, err := r.prevCommit.IsAncestor(r.curCommit)
+		if err != nil {
+			return nil, err
+		}
+		if descends {
+			seen, err = ancestors(r.prevCommit)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for {
+		pending, err := r.treePaths()
+		if err != nil {
+			return nil, err
+		}
+		result, err := r.walkLastCommits(pending, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		// Paths which were not touched since the recorded tip retain
+		// their recorded last commit.
+		complete := true
+		for fp := range pending {
+			h, ok := r.prevLastCommits[fp]
+			if !ok {
+				complete = false
+				break
+			}
+			result[fp], err = r.git.CommitObject(h)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if complete || seen == nil {
+			r.lastCommitCache = result
+			return result, nil
+		}
+		seen = nil // recorded last commits are incomplete, walk everything
+	}
+}
+
+// Returns the hashes of the last commits for all paths in the tree, see
+// lastCommits. If the tip did not change, the recorded ones are returned.
+func (r *Repo) lastCommitHashes() (map[string]plumbing.Hash, error) {
+	if r.lastCommitCache == nil && !r.TipChanged() && r.prevLastCommits != nil {
+		return r.prevLastCommits, nil
+	}
+
+	commits, err := r.lastCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]plumbing.Hash)
+	for fp, c := range commits {
+		hashes[fp] = c.Hash
+	}
+	return hashes, nil
+}
+
+// Returns a map of all paths in the tree, including directories, to the n
+// most recent commits which touched the path, newest first. The root
+// directory is identified by the empty path. The map is only computed once.
+func (r *Repo) history(n int) (map[string][]*object.Commit, error) {
+	if r.historyCache != nil {
+		return r.historyCache, nil
+	}
+
+	pending, err := r.treePaths()
+	if err != nil {
+		return nil, err
+	}
+	pending[""] = true
+
+	result := make(map[string][]*object.Commit)
+	err = r.walkHistory(nil, func(c *object.Commit, changed []string) error {
+		// A directory is only touched once per commit, even if the
+		// commit changed multiple files in the directory.
+		touched := map[string]bool{"": true}
+		for _, fp := range changed {
+			for ; fp != "."; fp = path.Dir(fp) {
+				touched[fp] = true
+			}
+		}
+
+		for fp := range touched {
+			if !pending[fp] {
+				continue
+			}
+
+			result[fp] = append(result[fp], c)
+			if len(result[fp]) >= n {
+				delete(pending, fp)
+			}
+		}
+
+		if len(pending) == 0 {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.historyCache = result
+	return result, nil
+}
+
+// WalkRetagged calls fn for each page of the tree whose n most recent
+// commits, as returned by RepoPage.History, include a commit whose tags
+// changed since the state was recorded. If no state was read, or if the
+// state is outdated, fn is not called as Walk already visits all pages.
+func (r *Repo) WalkRetagged(n int, fn WalkFunc) error {
+	if r.prevTree == nil || !r.TagsChanged() || r.root().outdated {
+		return nil
+	}
+
+	retagged, err := r.retaggedCommits()
+	if err != nil || len(retagged) == 0 {
+		return err
+	}
+	history, err := r.history(n)
+	if err != nil {
+		return err
+	}
+
+	isRetagged := func(c *object.Commit) bool {
+		return retagged[c.Hash]
+	}
+	for _, fp := range slices.Sorted(maps.Keys(history)) {
+		if !slices.ContainsFunc(history[fp], isRetagged) {
+			continue
+		}
+
+		name, page := ".", r.indexPage()
+		if fp != "" {
+			entry, err := r.curTree.FindEntry(fp)
+			if err != nil {
+				return err
+			}
+			name = fp
+			page, err = r.page(entry.Hash, entry.Mode, fp)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = fn(name, page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package gitweb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MIME type of SVG images, which are not detected by net/http.
+	svgType = "image/svg+xml"
+
+	// Amount of bytes used to detect the type of an image, SVG images
+	// may start with a long XML prolog.
+	sniffLen = 4096
+)
+
+// Image represents information for a single image file.
+type Image struct {
+	Type   string // MIME type
+	Width  int    // Zero if unknown
+	Height int    // Zero if unknown
+}
+
+func (i *Image) IsSVG() bool {
+	return i.Type == svgType
+}
+
+// Reports whether the given data is the beginning of an SVG image.
+func isSVG(data []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return tok.Name.Local == "svg"
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) != 0 {
+				return false
+			}
+		}
+	}
+}
+
+// Parses a length of an SVG image, only lengths in pixels are supported.
+func svgLength(s string) int {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+// Returns the dimensions of the given SVG image, either from the width and
+// height attributes of the root element or from its view box.
+func svgSize(r io.Reader) (int, int) {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return 0, 0
+		}
+		elem, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var width, height int
+		var viewBox []string
+		for _, attr := range elem.Attr {
+			switch attr.Name.Local {
+			case "width":
+				width = svgLength(attr.Value)
+			case "height":
+				height = svgLength(attr.Value)
+			case "viewBox":
+				viewBox = strings.Fields(strings.ReplaceAll(attr.Value, ",", " "))
+			}
+		}
+		if (width == 0 || height == 0) && len(viewBox) == 4 {
+			width, height = svgLength(viewBox[2]), svgLength(viewBox[3])
+		}
+
+		return width, height
+	}
+}
+
+// Image returns information for the current file if it is a PNG, JPEG,
+// GIF, WebP, or SVG image. The type is detected from the content of the
+// file. If the file is not an image, nil is returned.
+func (r *RepoPage) Image() (*Image, error) {
+	if r.CurrentFile.IsDir() || r.CurrentFile.IsSubmodule() || r.CurrentFile.IsSymlink() {
+		return nil, nil
+	}
+
+	file, err := r.Blob()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[0:n]
+	content := io.MultiReader(bytes.NewReader(head), reader)
+
+	img := &Image{Type: http.DetectContentType(head)}
+	switch img.Type {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		config, _, err := image.DecodeConfig(content)
+		if err == nil {
+			img.Width, img.Height = config.Width, config.Height
+		}
+	default:
+		if !isSVG(head) {
+			return nil, nil
+		}
+		img.Type = svgType
+		img.Width, img.Height = svgSize(content)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,177 @@
+package gitweb
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// LogPage represents a single page of the commit log. Pages are numbered
+// starting with the oldest commits, thereby new commits usually only affect
+// the newest pages.
+type LogPage struct {
+	*Repo
+
+	Commits []*object.Commit // Newest first
+	Number  int              // Starting at 1
+
+	pages int
+}
+
+// LogFunc is called for each page of the commit log, if the page is nil
+// the page with the given number was removed.
+type LogFunc func(number int, page *LogPage) error
+
+// Returns true if there is a page with older commits.
+func (p *LogPage) HasOlder() bool {
+	return p.Number > 1
+}
+
+// Returns true if there is a page with newer commits.
+func (p *LogPage) HasNewer() bool {
+	return p.Number < p.pages
+}
+
+// Reports whether the page contains any of the given commits.
+func (p *LogPage) hasAny(commits map[plumbing.Hash]bool) bool {
+	for _, c := range p.Commits {
+		if commits[c.Hash] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns all commits reachable from the given commit, newest first. If
+// configured, only the first parent of each commit is followed.
+func (r *Repo) walkLog(tip *object.Commit) ([]*object.Commit, error) {
+	var commits []*object.Commit
+	if r.Conf.FirstParent {
+		for c := tip; ; {
+			commits = append(commits, c)
+			if c.NumParents() == 0 {
+				break
+			}
+
+			var err error
+			c, err = c.Parent(0)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return commits, nil
+	}
+
+	iter, err := r.git.Log(&git.LogOptions{From: tip.Hash, Order: git.LogOrderDFSPost})
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// Returns the commit log of the tip, newest first. The log is only
+// computed once.
+func (r *Repo) log() ([]*object.Commit, error) {
+	if r.logCache != nil {
+		return r.logCache, nil
+	}
+
+	commits, err := r.walkLog(r.curCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logCache = commits
+	return commits, nil
+}
+
+// Returns the amount of log pages for the given amount of commits.
+func logPages(commits, size int) int {
+	return (commits + size - 1) / size
+}
+
+// WalkLog calls fn for each page of the commit log, with the given amount
+// of commits per page, which changed since the state was recorded. This
+// includes pages with commits whose tags changed. If no state was read,
+// or if the state is outdated, fn is called for all pages.
+func (r *Repo) WalkLog(size int, fn LogFunc) error {
+	if r.prevCommit != nil && !r.TipChanged() && !r.TagsChanged() {
+		return nil // avoid walking the history
+	}
+
+	commits, err := r.log()
+	if err != nil {
+		return err
+	}
+	pages := logPages(len(commits), size)
+
+	var retagged map[plumbing.Hash]bool
+	if r.prevCommit != nil && !r.root().outdated {
+		retagged, err = r.retaggedCommits()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pages are numbered starting with the oldest commits, hence commits
+	// are compared starting with the oldest commit to find the first
+	// page which changed.
+	first := 1
+	if r.prevCommit != nil && !r.root().outdated && !r.TipChanged() {
+		first = pages + 1 // only tags changed
+	} else if r.prevCommit != nil && !r.root().outdated {
+		prevCommits, err := r.walkLog(r.prevCommit)
+		if err != nil {
+			return err
+		}
+
+		var same int
+		for same < min(len(commits), len(prevCommits)) {
+			cur := commits[len(commits)-same-1]
+			prev := prevCommits[len(prevCommits)-same-1]
+			if cur.Hash != prev.Hash {
+				break
+			}
+			same++
+		}
+
+		// The last previous page needs to link to newly added pages, and
+		// the last current page must no longer link to removed pages.
+		first = min(same, len(prevCommits)-1, len(commits)-1)/size + 1
+		for n := logPages(len(prevCommits), size); n > pages; n-- {
+			err = fn(n, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for n := 1; n <= pages; n++ {
+		end := len(commits) - (n-1)*size
+		page := &LogPage{
+			Repo:    r,
+			Commits: commits[max(end-size, 0):end],
+			Number:  n,
+			pages:   pages,
+		}
+		if n < first && !page.hasAny(retagged) {
+			continue
+		}
+
+		err = fn(n, page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
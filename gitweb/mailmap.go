@@ -0,0 +1,177 @@
+package gitweb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// File name of the git mailmap file.
+	mailmapFn = ".mailmap"
+)
+
+// Identity as matched by a mailmap entry, in lower case as names and email
+// addresses are compared case-insensitively. An empty name matches all
+// identities with the given email address.
+type mailmapKey struct {
+	name  string
+	email string
+}
+
+// Canonical identity of a mailmap entry, empty fields are not replaced.
+type mailmapEntry struct {
+	name  string
+	email string
+}
+
+// Mailmap maps identities of authors and committers to canonical ones,
+// see gitmailmap(5).
+type mailmap struct {
+	entries map[mailmapKey]mailmapEntry
+	digest  string // Hash of all mailmap files, empty if there are none
+}
+
+// Parses a name followed by an email address enclosed in angle brackets,
+// the remainder of the string is returned as well.
+func parseIdent(s string) (name, email, rest string, ok bool) {
+	start := strings.IndexByte(s, '<')
+	if start == -1 {
+		return "", "", "", false
+	}
+	end := strings.IndexByte(s[start:], '>')
+	if end == -1 {
+		return "", "", "", false
+	}
+	end += start
+
+	return strings.TrimSpace(s[0:start]), s[start+1 : end], s[end+1:], true
+}
+
+// Adds all entries of the given mailmap file, entries for the same identity
+// are merged with existing ones.
+func (m *mailmap) parse(data string) {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		name, email, rest, ok := parseIdent(line)
+		if !ok {
+			continue
+		}
+		entry := mailmapEntry{name: name, email: email}
+
+		// If only a single email is given, it is the commit email
+		// and only the name is replaced.
+		key := mailmapKey{email: strings.ToLower(email)}
+		oldName, oldEmail, _, ok := parseIdent(rest)
+		if ok {
+			key = mailmapKey{strings.ToLower(oldName), strings.ToLower(oldEmail)}
+		} else {
+			entry.email = ""
+		}
+
+		prev := m.entries[key]
+		if entry.name == "" {
+			entry.name = prev.name
+		}
+		if entry.email == "" {
+			entry.email = prev.email
+		}
+		m.entries[key] = entry
+	}
+}
+
+// Returns the canonical identity for the given signature.
+func (m *mailmap) apply(sig object.Signature) object.Signature {
+	if m == nil {
+		return sig
+	}
+
+	email := strings.ToLower(sig.Email)
+	entry, ok := m.entries[mailmapKey{strings.ToLower(sig.Name), email}]
+	if !ok {
+		entry, ok = m.entries[mailmapKey{email: email}]
+		if !ok {
+			return sig
+		}
+	}
+
+	if entry.name != "" {
+		sig.Name = entry.name
+	}
+	if entry.email != "" {
+		sig.Email = entry.email
+	}
+	return sig
+}
+
+// Loads the mailmap from the .mailmap file of the tree and the file
+// configured via the mailmap.file option, which takes precedence. A
+// relative mailmap.file is relative to the repository.
+func (r *Repo) loadMailmap() (*mailmap, error) {
+	m := &mailmap{entries: make(map[mailmapKey]mailmapEntry)}
+	h := sha256.New()
+
+	file, err := r.curTree.File(mailmapFn)
+	if err == nil {
+		data, err := file.Contents()
+		if err != nil {
+			return nil, err
+		}
+		h.Write([]byte(data))
+		m.parse(data)
+	} else if err != object.ErrFileNotFound {
+		return nil, err
+	}
+
+	c, err := r.git.Config()
+	if err != nil {
+		return nil, err
+	}
+	if fp := c.Raw.Section("mailmap").Option("file"); fp != "" {
+		if home, found := strings.CutPrefix(fp, "~/"); found {
+			dir, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			fp = filepath.Join(dir, home)
+		} else if !filepath.IsAbs(fp) {
+			fp = filepath.Join(r.Path, fp)
+		}
+
+		// Like git, ignore the file if it does not exist.
+		data, err := os.ReadFile(fp)
+		if err == nil {
+			h.Write([]byte{0})
+			h.Write(data)
+			m.parse(string(data))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if len(m.entries) > 0 {
+		m.digest = hex.EncodeToString(h.Sum(nil))
+	}
+	return m, nil
+}
+
+// Author returns the author of the given commit, mapped to its canonical
+// identity using the mailmap of the repository.
+func (r *Repo) Author(c *object.Commit) object.Signature {
+	return r.mailmap.apply(c.Author)
+}
+
+// Committer returns the committer of the given commit, mapped to its
+// canonical identity using the mailmap of the repository.
+func (r *Repo) Committer(c *object.Commit) object.Signature {
+	return r.mailmap.apply(c.Committer)
+}
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -29,15 +31,20 @@ type CommitInfo struct {
 var (
 	ExpectedDirectory = errors.New("Expected directory")
 	ExpectedSubmodule = errors.New("Expected submodule")
+	ExpectedSymlink   = errors.New("Expected symbolic link")
 	ExpectedRegular   = errors.New("Expected regular file")
 )
 
-func (r *RepoPage) Files() ([]RepoFile, error) {
+func (r *RepoPage) Files() ([]RepoEntry, error) {
 	if !r.CurrentFile.IsDir() {
 		return nil, ExpectedDirectory
 	}
+	lastCommits, err := r.lastCommits()
+	if err != nil {
+		return nil, err
+	}
 
-	var entries []RepoFile
+	var entries []RepoEntry
 	basepath := filepath.Base(r.CurrentFile.Path)
 
 	walker := object.NewTreeWalker(r.tree, false, nil)
@@ -51,12 +58,33 @@ func (r *RepoPage) Files() ([]RepoFile, error) {
 		}
 
 		relpath := filepath.Join(basepath, name)
-		file := RepoFile{
-			Path: filepath.ToSlash(relpath),
-			mode: f.Mode,
+		fullpath := path.Join(r.CurrentFile.Path, name)
+		entry := RepoEntry{
+			RepoFile: RepoFile{
+				Path: filepath.ToSlash(relpath),
+				mode: f.Mode,
+				hash: f.Hash,
+			},
+			LastCommit: lastCommits[fullpath],
+		}
+		if entry.IsSymlink() {
+			entry.Symlink, err = r.symlink(fullpath, f.Hash)
+			if err != nil {
+				return nil, err
+			}
+		} else if f.Mode.IsFile() {
+			entry.Size, err = r.git.Storer.EncodedObjectSize(f.Hash)
+			if err != nil {
+				return nil, err
+			}
+		} else if entry.IsSubmodule() {
+			entry.Submodule, err = r.submodule(fullpath, f.Hash)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		entries = append(entries, file)
+		entries = append(entries, entry)
 	}
 
 	sort.Sort(byType(entries))
@@ -64,22 +92,40 @@ func (r *RepoPage) Files() ([]RepoFile, error) {
 }
 
 func (r *RepoPage) Commits() (*CommitInfo, error) {
+	return r.RecentCommits(r.maxCommits)
+}
+
+// RecentCommits returns the n most recent commits which touched the
+// current file, the total amount of commits is counted nonetheless.
+func (r *RepoPage) RecentCommits(n uint) (*CommitInfo, error) {
 	var total, numCommits uint
 
-	logOpts := &git.LogOptions{Order: git.LogOrderDFSPost}
-	if r.CurrentFile.Path != "" {
-		logOpts.PathFilter = func(fp string) bool {
-			return fp == r.CurrentFile.Path
+	// The commits of the index are also used for the commit log.
+	if r.CurrentFile.Path == "" {
+		commits, err := r.log()
+		if err != nil {
+			return nil, err
 		}
+
+		total = uint(len(commits))
+		return &CommitInfo{commits[0:min(n, total)], total}, nil
+	}
+
+	logOpts := &git.LogOptions{
+		From:  r.curCommit.Hash,
+		Order: git.LogOrderDFSPost,
+		PathFilter: func(fp string) bool {
+			return fp == r.CurrentFile.Path
+		},
 	}
 	iter, err := r.git.Log(logOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	commits := make([]*object.Commit, r.maxCommits)
+	commits := make([]*object.Commit, n)
 	err = iter.ForEach(func(c *object.Commit) error {
-		if numCommits < r.maxCommits {
+		if numCommits < n {
 			commits[numCommits] = c
 			numCommits++
 		}
@@ -95,8 +141,20 @@ func (r *RepoPage) Commits() (*CommitInfo, error) {
 	return &CommitInfo{commits, total}, nil
 }
 
+// History returns the n most recent commits which touched the current
+// file, merge commits are not included. The history of all files is
+// computed in a single walk, n must thus be the same for all pages.
+func (r *RepoPage) History(n uint) ([]*object.Commit, error) {
+	history, err := r.history(int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return history[r.CurrentFile.Path], nil
+}
+
 func (r *RepoPage) Blob() (*object.File, error) {
-	if r.CurrentFile.IsDir() || r.CurrentFile.IsSubmodule() {
+	if r.CurrentFile.IsDir() || r.CurrentFile.IsSubmodule() || r.CurrentFile.IsSymlink() {
 		return nil, ExpectedRegular
 	}
 
@@ -107,36 +165,36 @@ func (r *RepoPage) Blob() (*object.File, error) {
 	return commit.File(r.CurrentFile.Path)
 }
 
-func (r *RepoPage) Submodule(file *RepoFile) (*object.File, error) {
+func (r *RepoPage) Submodule(file *RepoFile) (*Submodule, error) {
 	if !file.IsSubmodule() {
 		return nil, ExpectedSubmodule
 	}
 
-	// git-go only seems to have very limited support for submodules
-	// in bare repositories. Hence, just display .gitmodules for now.
-	commit, err := r.Tip()
-	if err != nil {
-		return nil, err
+	return r.submodule(file.Path, file.hash)
+}
+
+func (r *RepoPage) Symlink(file *RepoFile) (*Symlink, error) {
+	if !file.IsSymlink() {
+		return nil, ExpectedSymlink
 	}
-	return commit.File(".gitmodules")
+
+	return r.symlink(file.Path, file.hash)
 }
 
+// Returns the name of the README file of the current directory, i.e. the
+// regular file matching the first configured README pattern. If multiple
+// files match the same pattern, the first one in tree order is used.
 func (r *RepoPage) findReadme() (string, error) {
 	var result string
-
-	walker := object.NewTreeWalker(r.tree, false, nil)
-	defer walker.Close()
-	for {
-		_, f, err := walker.Next()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return "", err
+	rank := -1
+	for _, entry := range r.tree.Entries {
+		if !entry.Mode.IsFile() || entry.Mode == filemode.Symlink {
+			continue
 		}
 
-		if isReadme(f.Name) {
-			result = f.Name
-			break
+		n := r.Conf.readmeRank(entry.Name)
+		if n != -1 && (rank == -1 || n < rank) {
+			result, rank = entry.Name, n
 		}
 	}
 
@@ -147,6 +205,15 @@ func (r *RepoPage) findReadme() (string, error) {
 	return result, nil
 }
 
+// ReadmeName returns the name of the README file of the current directory.
+func (r *RepoPage) ReadmeName() (string, error) {
+	if !r.CurrentFile.IsDir() {
+		return "", ExpectedDirectory
+	}
+
+	return r.findReadme()
+}
+
 func (r *RepoPage) Readme() (string, error) {
 	if !r.CurrentFile.IsDir() {
 		return "", ExpectedDirectory
@@ -0,0 +1,117 @@
+package gitweb
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Preview represents the displayed content of a file, which is truncated
+// if the file exceeds the configured limits.
+type Preview struct {
+	Contents string
+	Size     int64 // Size of the entire file in bytes
+
+	// Whether Contents only contains the beginning of the file.
+	Truncated bool
+	// Whether the content was omitted entirely, as the size of the
+	// tree exceeds the configured limit.
+	Omitted bool
+}
+
+// Reports whether the total size of all files in the tree exceeds the
+// configured limit. The size is only computed once and without reading
+// the content of the files.
+func (r *Repo) treeTooLarge() (bool, error) {
+	if r.Conf.TreeMaxSize == 0 {
+		return false, nil
+	} else if r.treeSizeCache != nil {
+		return *r.treeSizeCache > r.Conf.TreeMaxSize, nil
+	}
+
+	var size int64
+	walker := object.NewTreeWalker(r.curTree, true, nil)
+	defer walker.Close()
+	for size <= r.Conf.TreeMaxSize {
+		_, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return false, err
+		}
+		if entry.Mode == filemode.Dir || entry.Mode == filemode.Submodule {
+			continue
+		}
+
+		n, err := r.git.Storer.EncodedObjectSize(entry.Hash)
+		if err != nil {
+			return false, err
+		}
+		size += n
+	}
+
+	r.treeSizeCache = &size
+	return size > r.Conf.TreeMaxSize, nil
+}
+
+// Preview returns the content of the current file, limited to the
+// configured maximum amount of bytes and lines. If the content is
+// truncated, it ends with a complete line.
+func (r *RepoPage) Preview() (*Preview, error) {
+	file, err := r.Blob()
+	if err != nil {
+		return nil, err
+	}
+	preview := &Preview{Size: file.Size}
+
+	omit, err := r.treeTooLarge()
+	if err != nil {
+		return nil, err
+	} else if omit {
+		preview.Omitted = true
+		return preview, nil
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var src io.Reader = reader
+	if limit := r.Conf.BlobMaxSize; limit > 0 && file.Size > limit {
+		src = io.LimitReader(reader, limit)
+		preview.Truncated = true
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't cut the last line (or even a character) in half.
+	if preview.Truncated {
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[0 : i+1]
+		}
+	}
+	if limit := r.Conf.BlobMaxLines; limit > 0 {
+		end := 0
+		for n := 0; n < limit && end < len(data); n++ {
+			i := bytes.IndexByte(data[end:], '\n')
+			if i == -1 {
+				end = len(data)
+				break
+			}
+			end += i + 1
+		}
+		if end < len(data) {
+			data = data[0:end]
+			preview.Truncated = true
+		}
+	}
+
+	preview.Contents = string(data)
+	return preview, nil
+}
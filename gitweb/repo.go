@@ -2,13 +2,15 @@ package gitweb
 
 import (
 	"errors"
+	"fmt"
 	"io"
-	"net/url"
+	"maps"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
@@ -22,13 +24,59 @@ type Repo struct {
 	curTree  *object.Tree
 	prevTree *object.Tree // may be nil
 
+	curCommit  *object.Commit
+	prevCommit *object.Commit // may be nil
+
 	git        *git.Repository
 	maxCommits uint
 
-	Conf  Config
-	Path  string
-	Title string
-	URL   string
+	// Repository for the default branch, nil for the default branch itself.
+	head *Repo
+	// Additional branches, only set for the default branch.
+	branches []*Repo
+
+	// Tag names mapped to the referenced object, only set for the
+	// default branch as tags are shared between all branches.
+	curTags  map[string]plumbing.Hash
+	prevTags map[string]plumbing.Hash // may be nil
+
+	// Mailmap of the default branch, which is used for all branches.
+	mailmap *mailmap
+	// Verifier for signatures, only set for the default branch. It is
+	// loaded on first use, nil if verification is not configured.
+	verifier       *verifier
+	verifierLoaded bool
+
+	// Cache for the last commit which touched each path in the tree.
+	lastCommitCache map[string]*object.Commit
+	// Last commit which touched each path in the recorded tree, may be nil.
+	prevLastCommits map[string]plumbing.Hash
+	// Cache for the most recent commits which touched each path in the tree.
+	historyCache map[string][]*object.Commit
+	// Cache for the parsed .gitmodules file of the tree.
+	modulesCache *config.Modules
+	// Cache for the commit log of the tip.
+	logCache []*object.Commit
+	// Cache for the stats of commits, only set for the default branch
+	// as commits are shared between all branches.
+	statsCache map[plumbing.Hash]CommitStats
+	// Cache for the names of the tags of each commit, only set for the
+	// default branch as tags are shared between all branches.
+	tagsCache map[plumbing.Hash][]string
+	// Cache for the total size of all files in the tree, only computed
+	// up to the point where it exceeds the configured limit.
+	treeSizeCache *int64
+
+	// Whether the state was generated by a different build, only set
+	// for the default branch. If so, all pages need to be rebuild.
+	outdated bool
+
+	Conf      Config
+	Path      string
+	Name      string // Derived from the path, e.g. for use in links
+	Title     string // Name unless overwritten by the configuration
+	CloneURLs []CloneURL
+	Branch    string // Empty for the default branch
 }
 
 type WalkFunc func(string, *RepoPage) error
@@ -38,16 +86,17 @@ const (
 	descFn = "description"
 )
 
-func NewRepo(fp string, cloneURL *url.URL, commits uint) (*Repo, error) {
+// NewRepo opens the repository at the given path. The given clone URLs
+// are used unless the repository configures clone URLs itself, a %s
+// placeholder in the URLs is replaced with the name of the repository.
+func NewRepo(fp string, cloneURLs []CloneURL, commits uint) (*Repo, error) {
 	absFp, err := filepath.Abs(fp)
 	if err != nil {
 		return nil, err
 	}
 
-	r := &Repo{Path: absFp, Title: repoTitle(absFp), maxCommits: commits}
-	if cloneURL != nil {
-		r.URL = cloneURL.String()
-	}
+	name := repoName(absFp)
+	r := &Repo{Path: absFp, Name: name, Title: name, maxCommits: commits}
 
 	fs := osfs.New(absFp)
 	if _, err := fs.Stat(git.GitDirName); err == nil {
@@ -65,36 +114,62 @@ func NewRepo(fp string, cloneURL *url.URL, commits uint) (*Repo, error) {
 		return nil, err
 	}
 
-	// TODO: Make head a public member of the Repository struct.
-	head, err := r.Tip()
+	r.Conf, err = loadConfig(r.git)
 	if err != nil {
 		return nil, err
 	}
-	r.curTree, err = head.Tree()
+
+	// The configuration takes precedence over the passed clone URLs.
+	if r.Conf.CloneURLs != nil {
+		cloneURLs = r.Conf.CloneURLs
+	}
+	for _, cloneURL := range cloneURLs {
+		r.CloneURLs = append(r.CloneURLs, cloneURL.expand(filepath.Base(absFp)))
+	}
+	if r.Conf.Title != "" {
+		r.Title = r.Conf.Title
+	}
+
+	head := plumbing.HEAD
+	if r.Conf.DefaultBranch != "" {
+		head = plumbing.NewBranchReferenceName(r.Conf.DefaultBranch)
+	}
+	err = r.resolve(head)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("default branch: %w", err)
 	}
 
-	r.Conf, err = loadConfig(r.git)
+	r.curTags, err = r.tagRefs()
 	if err != nil {
 		return nil, err
 	}
 
+	r.mailmap, err = r.loadMailmap()
+	if err != nil {
+		return nil, err
+	}
 	return r, nil
 }
 
-func (r *Repo) ReadState(fp string) error {
-	stateFile, err := os.Open(fp)
+// Returns the repository for the default branch.
+func (r *Repo) root() *Repo {
+	if r.head != nil {
+		return r.head
+	}
+	return r
+}
+
+func (r *Repo) resolve(name plumbing.ReferenceName) error {
+	ref, err := r.git.Reference(name, true)
 	if err != nil {
 		return err
 	}
 
-	h, err := readHashFile(stateFile)
+	r.curCommit, err = r.git.CommitObject(ref.Hash())
 	if err != nil {
 		return err
 	}
-
-	r.prevTree, err = r.git.TreeObject(h)
+	r.curTree, err = r.curCommit.Tree()
 	if err != nil {
 		return err
 	}
@@ -102,40 +177,72 @@ func (r *Repo) ReadState(fp string) error {
 	return nil
 }
 
-func (r *Repo) WriteState(fp string) error {
-	stateFile, err := os.Create(fp)
-	if err != nil {
-		return err
+// AddBranch registers an additional branch of the repository which should
+// be rendered. Branches must be added before the state is read. Adding an
+// already registered branch returns the existing repository.
+func (r *Repo) AddBranch(name string) (*Repo, error) {
+	if r.head != nil {
+		return r.root().AddBranch(name)
+	}
+	for _, branch := range r.branches {
+		if branch.Branch == name {
+			return branch, nil
+		}
 	}
 
-	_, err = stateFile.WriteString(r.curTree.Hash.String())
+	branch := &Repo{
+		git:        r.git,
+		maxCommits: r.maxCommits,
+		head:       r,
+		mailmap:    r.mailmap,
+		Conf:       r.Conf,
+		Path:       r.Path,
+		Title:      r.Title,
+		CloneURLs:  r.CloneURLs,
+		Branch:     name,
+	}
+
+	err := branch.resolve(plumbing.NewBranchReferenceName(name))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("branch %q: %w", name, err)
 	}
 
-	return stateFile.Close()
+	r.branches = append(r.branches, branch)
+	return branch, nil
 }
 
-func (r *Repo) Tip() (*object.Commit, error) {
-	head, err := r.git.Head()
-	if err != nil {
-		return nil, err
+// Refs returns the repository for the default branch followed by the
+// repositories for all branches registered via AddBranch.
+func (r *Repo) Refs() []*Repo {
+	if r.head != nil {
+		return r.root().Refs()
 	}
 
-	hash := head.Hash()
-	commit, err := r.git.CommitObject(hash)
-	if err != nil {
-		return nil, err
-	}
+	return append([]*Repo{r}, r.branches...)
+}
+
+// TagsChanged reports whether the set of tags differs from the one
+// recorded in the tag state file.
+func (r *Repo) TagsChanged() bool {
+	root := r.root()
+	return root.outdated || root.prevTags == nil || !maps.Equal(root.prevTags, root.curTags)
+}
+
+func (r *Repo) Tip() (*object.Commit, error) {
+	return r.curCommit, nil
+}
 
-	return commit, nil
+// TipChanged reports whether the tip differs from the one recorded in the
+// state file.
+func (r *Repo) TipChanged() bool {
+	return r.prevCommit == nil || r.prevCommit.Hash != r.curCommit.Hash
 }
 
 func (r *Repo) indexPage() *RepoPage {
 	return &RepoPage{
 		Repo:        r,
 		tree:        r.curTree,
-		CurrentFile: RepoFile{mode: filemode.Dir, Path: ""},
+		CurrentFile: RepoFile{mode: filemode.Dir, hash: r.curTree.Hash, Path: ""},
 	}
 }
 
@@ -191,49 +298,86 @@ func (r *Repo) changedParents(tree *object.Tree, fp string) ([]string, error) {
 	return parents, nil
 }
 
-func (r *Repo) walkDiff(fn WalkFunc) error {
-	changes, err := object.DiffTree(r.prevTree, r.curTree)
+// Marks all parent directories of fp for rebuilding. The directory listings
+// of all parent directories contain the last commit which touched an entry
+// and thus need to be rebuild if the entry changed.
+func markParents(rebuildDirs map[string]bool, fp string) {
+	for dir := filepath.Dir(fp); dir != "."; dir = filepath.Dir(dir) {
+		rebuildDirs[dir] = true
+	}
+}
+
+// Calls fn for the removed file fp and for all parent directories which
+// have been removed implicitly. Returns the last removed path.
+func (r *Repo) walkRemoved(fn WalkFunc, fp string) (string, error) {
+	err := fn(fp, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
-	patch, err := changes.Patch()
+
+	// Assuming the file pointed to by fp was deleted in the
+	// newTree, check which parent directories are now also
+	// deleted implicitly (because they are empty now).
+	deadParents, err := r.changedParents(r.curTree, fp)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range deadParents {
+		err = fn(p, nil)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(deadParents) > 0 {
+		return deadParents[len(deadParents)-1], nil
+	}
+	return fp, nil
+}
+
+// Rebuilds all pages, pages of files which have been removed since the
+// state was recorded are removed.
+func (r *Repo) walkRebuild(fn WalkFunc) error {
+	changes, err := object.DiffTree(r.prevTree, r.curTree)
 	if err != nil {
 		return err
 	}
 
-	rebuildDirs := make(map[string]bool)
-	for _, filePatch := range patch.FilePatches() {
-		from, to := filePatch.Files()
-		if to == nil { // file was removed
-			err = fn(from.Path(), nil)
+	for _, change := range changes {
+		if change.To.Name == "" {
+			_, err = r.walkRemoved(fn, change.From.Name)
 			if err != nil {
 				return err
 			}
+		}
+	}
 
-			// Assuming the file pointed to by fp was deleted in the
-			// newTree, check which parent directories are now also
-			// deleted implicitly (because they are empty now).
-			deadParents, err := r.changedParents(r.curTree, from.Path())
-			if err != nil {
-				return err
-			}
+	return r.walkTree(fn)
+}
 
-			for _, p := range deadParents {
-				err = fn(p, nil)
-				if err != nil {
-					return err
-				}
-			}
+func (r *Repo) walkDiff(fn WalkFunc) error {
+	// The changes are not converted to a patch, as patches do not
+	// contain any files for submodules.
+	changes, err := object.DiffTree(r.prevTree, r.curTree)
+	if err != nil {
+		return err
+	}
 
-			lastDead := from.Path()
-			if len(deadParents) > 0 {
-				lastDead = deadParents[len(deadParents)-1]
+	rebuildDirs := make(map[string]bool)
+	for _, change := range changes {
+		from, to := change.From, change.To
+		if to.Name == "" { // file was removed
+			lastDead, err := r.walkRemoved(fn, from.Name)
+			if err != nil {
+				return err
 			}
 			rebuildDirs[filepath.Dir(lastDead)] = true
+			markParents(rebuildDirs, lastDead)
 
 			continue
-		} else if from == nil { // created a new file
-			dest := to.Path()
+		} else if from.Name == "" { // created a new file
+			dest := to.Name
 
 			newParents, err := r.changedParents(r.prevTree, dest)
 			if err != nil {
@@ -248,12 +392,24 @@ func (r *Repo) walkDiff(fn WalkFunc) error {
 			rebuildDirs[filepath.Dir(lastNew)] = true
 		}
 
-		fp := to.Path()
-		if isReadme(fp) {
+		fp := to.Name
+		markParents(rebuildDirs, fp)
+		if r.Conf.isReadme(fp) {
 			rebuildDirs[filepath.Dir(fp)] = true
+		} else if fp == modulesFn {
+			// Submodule URLs are displayed on the submodule pages
+			// and in the listing of the containing directories.
+			submodules, err := r.submodulePaths()
+			if err != nil {
+				return err
+			}
+			for _, sp := range submodules {
+				rebuildDirs[sp] = true
+				rebuildDirs[filepath.Dir(sp)] = true
+			}
 		}
 
-		page, err := r.page(to.Hash(), to.Mode(), fp)
+		page, err := r.page(to.TreeEntry.Hash, to.TreeEntry.Mode, fp)
 		if err != nil {
 			return err
 		}
@@ -263,10 +419,12 @@ func (r *Repo) walkDiff(fn WalkFunc) error {
 		}
 	}
 
-	// If the tree changed, assume that we need to rebuild the index.
-	// For example, because the commits are listed there. This a somewhat
-	// depp-specific assumption which is hackily backed into the gitweb library.
-	if r.prevTree.Hash != r.curTree.Hash {
+	// If the tree, the tip, or the tags changed, assume that we need to
+	// rebuild the index. For example, because the commits (and the tags
+	// pointing to them) are listed there. This a
+	// somewhat depp-specific assumption which is hackily backed into the
+	// gitweb library.
+	if r.prevTree.Hash != r.curTree.Hash || r.TipChanged() || r.TagsChanged() {
 		rebuildDirs["."] = true
 	}
 
@@ -298,16 +456,102 @@ func (r *Repo) walkDiff(fn WalkFunc) error {
 func (r *Repo) Walk(fn WalkFunc) error {
 	if r.prevTree == nil {
 		return r.walkTree(fn)
+	} else if r.root().outdated {
+		return r.walkRebuild(fn)
 	} else {
 		return r.walkDiff(fn)
 	}
 }
 
+// Returns the tips of all rendered branches and the commits of all tags,
+// either for the current or the state recorded in the state files.
+func (r *Repo) tips(prev bool) ([]*object.Commit, error) {
+	root := r.root()
+	if prev && root.outdated {
+		return nil, nil
+	}
+
+	var tips []*object.Commit
+	for _, ref := range root.Refs() {
+		commit := ref.curCommit
+		if prev {
+			commit = ref.prevCommit
+		}
+		if commit != nil {
+			tips = append(tips, commit)
+		}
+	}
+
+	tags := root.curTags
+	if prev {
+		tags = root.prevTags
+	}
+	for _, h := range tags {
+		commit, err := root.tagCommit(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) || errors.Is(err, object.ErrUnsupportedObject) {
+			continue // tag does not point to an (existing) commit
+		} else if err != nil {
+			return nil, err
+		}
+
+		tips = append(tips, commit)
+	}
+
+	return tips, nil
+}
+
+// WalkCommits calls fn for each commit reachable from the tip of any
+// rendered branch or from any tag which was not reachable from the tips
+// and tags recorded in the state file. If no state was read, or if the
+// state is outdated, fn is called for all commits.
+func (r *Repo) WalkCommits(fn CommitFunc) error {
+	changed := r.TagsChanged()
+	for _, ref := range r.Refs() {
+		changed = changed || ref.TipChanged()
+	}
+	if !changed {
+		return nil // avoid walking the history
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+
+	prevTips, err := r.tips(true)
+	if err != nil {
+		return err
+	}
+	for _, tip := range prevTips {
+		err := object.NewCommitPreorderIter(tip, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	curTips, err := r.tips(false)
+	if err != nil {
+		return err
+	}
+	for _, tip := range curTips {
+		iter := object.NewCommitPreorderIter(tip, seen, nil)
+		err := iter.ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return fn(&CommitPage{Repo: r.root(), Commit: c})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Repo) page(hash plumbing.Hash, mode filemode.FileMode, fp string) (*RepoPage, error) {
 	page := &RepoPage{
 		Repo:        r,
 		tree:        nil,
-		CurrentFile: RepoFile{mode, filepath.ToSlash(fp)},
+		CurrentFile: RepoFile{mode, hash, filepath.ToSlash(fp)},
 	}
 
 	var err error
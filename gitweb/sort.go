@@ -1,7 +1,7 @@
 package gitweb
 
-// byType sorts RepoFiles by their object type (directories first).
-type byType []RepoFile
+// byType sorts RepoEntries by their object type (directories first).
+type byType []RepoEntry
 
 func (t byType) Len() int {
 	return len(t)
@@ -0,0 +1,218 @@
+package gitweb
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Version of the state file format.
+const stateVersion = 1
+
+// Build describes the generator which created the pages recorded in a state
+// file. If the build changes, all pages need to be rebuild. The meaning of
+// each field is up to the generator, fields are only compared for equality.
+type Build struct {
+	Version   string `json:"version"`   // Version of the generator
+	Templates string `json:"templates"` // Hash of all templates
+	Options   string `json:"options"`   // Hash of all options
+}
+
+type stateRef struct {
+	Branch string `json:"branch,omitempty"` // Empty for the default branch
+	Tree   string `json:"tree"`
+	Commit string `json:"commit"`
+
+	// Last commit which touched each path in the tree.
+	Paths map[string]string `json:"paths,omitempty"`
+}
+
+type state struct {
+	Version int               `json:"version"`
+	Build   Build             `json:"build"`
+	Refs    []stateRef        `json:"refs"`
+	Tags    map[string]string `json:"tags"`
+	Mailmap string            `json:"mailmap,omitempty"`
+	Keys    string            `json:"keys,omitempty"`
+}
+
+// Decodes a state file, returns false if the state file is malformed or
+// was written in a different format.
+func decodeState(data []byte) (*state, bool) {
+	var s state
+	err := json.Unmarshal(data, &s)
+	if err != nil || s.Version != stateVersion {
+		return nil, false
+	}
+
+	for _, ref := range s.Refs {
+		if !plumbing.IsHash(ref.Tree) || !plumbing.IsHash(ref.Commit) {
+			return nil, false
+		}
+		for _, h := range ref.Paths {
+			if !plumbing.IsHash(h) {
+				return nil, false
+			}
+		}
+	}
+	for _, h := range s.Tags {
+		if !plumbing.IsHash(h) {
+			return nil, false
+		}
+	}
+
+	return &s, true
+}
+
+// ReadState reads a state file written by WriteState. If the state was
+// written for a different build, a different set of branches, or with a
+// different mailmap or keys, all pages are rebuild. The same applies if
+// the state file is malformed, was written by a different version of
+// WriteState, or if the recorded objects no longer exist. Otherwise, only
+// pages for changes are rebuild.
+func (r *Repo) ReadState(fp string, build Build) error {
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+
+	root := r.root()
+	s, ok := decodeState(data)
+	if !ok {
+		root.outdated = true
+		return nil
+	}
+
+	refs := make(map[string]stateRef)
+	for _, ref := range s.Refs {
+		refs[ref.Branch] = ref
+	}
+	tags := make(map[string]plumbing.Hash)
+	for name, h := range s.Tags {
+		tags[name] = plumbing.NewHash(h)
+	}
+
+	// All pages link to all rendered branches, if the set of rendered
+	// branches changed, all pages need to be rebuild. The same applies
+	// to the mailmap and the keys for signatures, as authors and
+	// signatures are displayed on most pages.
+	verifier, err := r.signatureVerifier()
+	if err != nil {
+		return err
+	}
+	root.outdated = s.Build != build || len(refs) != len(r.Refs()) ||
+		s.Mailmap != root.mailmap.digest || s.Keys != verifier.keys()
+	for _, ref := range r.Refs() {
+		state, ok := refs[ref.Branch]
+		if !ok {
+			root.outdated = true
+			continue
+		}
+
+		// The recorded objects may have been removed, e.g. by
+		// garbage collection after a force push.
+		ref.prevTree, err = r.git.TreeObject(plumbing.NewHash(state.Tree))
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			ref.prevTree = nil
+			root.outdated = true
+			continue
+		} else if err != nil {
+			return err
+		}
+		ref.prevCommit, err = r.git.CommitObject(plumbing.NewHash(state.Commit))
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			ref.prevCommit = nil
+			root.outdated = true
+			continue
+		} else if err != nil {
+			return err
+		}
+		if state.Paths != nil {
+			ref.prevLastCommits = make(map[string]plumbing.Hash)
+			for fp, h := range state.Paths {
+				ref.prevLastCommits[fp] = plumbing.NewHash(h)
+			}
+		}
+	}
+	root.prevTags = tags
+
+	return nil
+}
+
+// Outdated reports whether the state was written for a different build,
+// a different set of branches, or with a different mailmap or keys. If
+// so, all pages are rebuild.
+func (r *Repo) Outdated() bool {
+	return r.root().outdated
+}
+
+// ReadLegacyState reads a state file written by older versions, which only
+// records the tree of the default branch. Since the build is unknown, all
+// pages are rebuild. If the state file is malformed or the recorded tree no
+// longer exists, the state file is ignored.
+func (r *Repo) ReadLegacyState(fp string) error {
+	data, err := os.ReadFile(fp)
+	if errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	r.root().outdated = true
+	h := strings.TrimSpace(string(data))
+	if err != nil || !plumbing.IsHash(h) {
+		return nil
+	}
+
+	r.prevTree, err = r.git.TreeObject(plumbing.NewHash(h))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		r.prevTree = nil
+		return nil
+	}
+	return err
+}
+
+// WriteState records the current state of all branches and tags, along
+// with the given build, in a state file.
+func (r *Repo) WriteState(fp string, build Build) error {
+	verifier, err := r.signatureVerifier()
+	if err != nil {
+		return err
+	}
+
+	s := state{
+		Version: stateVersion,
+		Build:   build,
+		Tags:    make(map[string]string),
+		Mailmap: r.root().mailmap.digest,
+		Keys:    verifier.keys(),
+	}
+	for _, ref := range r.Refs() {
+		lastCommits, err := ref.lastCommitHashes()
+		if err != nil {
+			return err
+		}
+
+		paths := make(map[string]string)
+		for fp, h := range lastCommits {
+			paths[fp] = h.String()
+		}
+		s.Refs = append(s.Refs, stateRef{
+			Branch: ref.Branch,
+			Tree:   ref.curTree.Hash.String(),
+			Commit: ref.curCommit.Hash.String(),
+			Paths:  paths,
+		})
+	}
+	for name, h := range r.root().curTags {
+		s.Tags[name] = h.String()
+	}
+
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fp, append(data, '\n'), 0644)
+}
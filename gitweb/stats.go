@@ -0,0 +1,100 @@
+package gitweb
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Version of the stats file format.
+const statsVersion = 1
+
+// CommitStats summarizes the changes introduced by a single commit.
+type CommitStats struct {
+	Files     int `json:"files"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+type statsFile struct {
+	Version int                    `json:"version"`
+	Commits map[string]CommitStats `json:"commits"`
+}
+
+// Stats returns the amount of changed files, added lines, and deleted
+// lines of the given commit. Like Diff, merge commits are compared against
+// their first parent. Stats are cached per commit, see ReadStats.
+func (r *Repo) Stats(c *object.Commit) (*CommitStats, error) {
+	root := r.root()
+	if root.statsCache == nil {
+		root.statsCache = make(map[plumbing.Hash]CommitStats)
+	}
+	if stats, ok := root.statsCache[c.Hash]; ok {
+		return &stats, nil
+	}
+
+	patch, err := commitPatch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats CommitStats
+	for _, fp := range patch.FilePatches() {
+		additions, deletions := countChanges(fp)
+		stats.Files++
+		stats.Additions += additions
+		stats.Deletions += deletions
+	}
+
+	root.statsCache[c.Hash] = stats
+	return &stats, nil
+}
+
+// ReadStats reads the stats of commits from a file written by WriteStats.
+// As the stats of a commit never change, they are never recomputed. If the
+// file is malformed or was written in a different format, it is ignored
+// and all stats are recomputed.
+func (r *Repo) ReadStats(fp string) error {
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+
+	var f statsFile
+	err = json.Unmarshal(data, &f)
+	if err != nil || f.Version != statsVersion {
+		return nil
+	}
+
+	cache := make(map[plumbing.Hash]CommitStats)
+	for h, stats := range f.Commits {
+		if !plumbing.IsHash(h) {
+			return nil
+		}
+		cache[plumbing.NewHash(h)] = stats
+	}
+
+	r.root().statsCache = cache
+	return nil
+}
+
+// WriteStats writes the stats of all commits read via ReadStats or
+// computed via Stats to a file.
+func (r *Repo) WriteStats(fp string) error {
+	f := statsFile{
+		Version: statsVersion,
+		Commits: make(map[string]CommitStats),
+	}
+	for h, stats := range r.root().statsCache {
+		f.Commits[h.String()] = stats
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fp, append(data, '\n'), 0644)
+}
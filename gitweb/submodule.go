@@ -0,0 +1,119 @@
+package gitweb
+
+import (
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// File name of the git submodule configuration file.
+	modulesFn = ".gitmodules"
+)
+
+// Submodule represents information for a single submodule.
+type Submodule struct {
+	Name   string // Empty if the submodule is not configured
+	URL    string // Empty if the submodule is not configured
+	Commit plumbing.Hash
+
+	// Link for the submodule URL, empty if the URL cannot be linked.
+	// Relative links are relative to the destination directory.
+	Link string
+}
+
+// Returns the submodule configuration of the tree, parsed only once.
+func (r *Repo) modules() (*config.Modules, error) {
+	if r.modulesCache != nil {
+		return r.modulesCache, nil
+	}
+
+	modules := config.NewModules()
+	file, err := r.curTree.File(modulesFn)
+	if err == nil {
+		data, err := file.Contents()
+		if err != nil {
+			return nil, err
+		}
+		err = modules.Unmarshal([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+	} else if err != object.ErrFileNotFound {
+		return nil, err
+	}
+
+	r.modulesCache = modules
+	return modules, nil
+}
+
+// Returns the submodule for the given slash separated path in the tree,
+// pinned to the given commit.
+func (r *Repo) submodule(fp string, commit plumbing.Hash) (*Submodule, error) {
+	modules, err := r.modules()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Submodule{Commit: commit}
+	for _, m := range modules.Submodules {
+		if m.Path == fp {
+			sub.Name = m.Name
+			sub.URL = m.URL
+			sub.Link = r.Conf.submoduleLink(m.URL)
+			break
+		}
+	}
+
+	return sub, nil
+}
+
+// Returns a link for the given submodule URL, using the configured
+// mappings of URL prefixes. If no mapping matches, http(s) URLs are
+// linked directly while other URLs are not linked at all.
+func (c *Config) submoduleLink(rawURL string) string {
+	var prefix string
+	for p := range c.SubmoduleLinks {
+		if strings.HasPrefix(rawURL, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix != "" {
+		return c.SubmoduleLinks[prefix] + strings.TrimPrefix(rawURL, prefix)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return rawURL
+	}
+
+	return ""
+}
+
+// Returns the paths of all submodules in the tree.
+func (r *Repo) submodulePaths() ([]string, error) {
+	var paths []string
+
+	walker := object.NewTreeWalker(r.curTree, true, nil)
+	defer walker.Close()
+	for {
+		fp, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if entry.Mode == filemode.Submodule {
+			paths = append(paths, filepath.FromSlash(fp))
+		}
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,59 @@
+package gitweb
+
+import (
+	"io"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Symlink represents information for a single symbolic link.
+type Symlink struct {
+	Target string
+
+	// Slash separated path of the link target in the tree, only
+	// valid if the target resolves to an entry of the tree.
+	Path   string
+	InTree bool
+}
+
+// Returns the symlink for the given slash separated path in the tree,
+// with the target stored in the blob of the given hash.
+func (r *Repo) symlink(fp string, blob plumbing.Hash) (*Symlink, error) {
+	obj, err := r.git.BlobObject(blob)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	target, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &Symlink{Target: string(target)}
+	if path.IsAbs(link.Target) {
+		return link, nil
+	}
+
+	resolved := path.Join(path.Dir(fp), link.Target)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return link, nil // target outside of repository
+	} else if resolved == "." {
+		link.InTree = true
+		return link, nil
+	}
+
+	_, err = r.curTree.FindEntry(resolved)
+	if err == nil {
+		link.Path = resolved
+		link.InTree = true
+	}
+
+	return link, nil
+}
@@ -0,0 +1,181 @@
+package gitweb
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Tag represents information for a single lightweight or annotated tag.
+type Tag struct {
+	Name   string
+	Hash   plumbing.Hash // Hash of the tag object or the tagged commit
+	Commit *object.Commit
+
+	// For lightweight tags, the tagger is the committer of the tagged
+	// commit and the message is empty. The tagger is mapped to its
+	// canonical identity using the mailmap.
+	Tagger  object.Signature
+	Message string
+
+	// Verification result for signed annotated tags, see Repo.Signature.
+	Signature *Signature
+}
+
+func (t *Tag) IsAnnotated() bool {
+	return t.Hash != t.Commit.Hash
+}
+
+// Tags returns all tags which point to a commit, newest first.
+func (r *Repo) Tags() ([]*Tag, error) {
+	iter, err := r.git.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []*Tag
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tag := &Tag{Name: ref.Name().Short(), Hash: ref.Hash()}
+
+		obj, err := r.git.TagObject(ref.Hash())
+		switch err {
+		case nil:
+			tag.Tagger = obj.Tagger
+			tag.Message = obj.Message
+			tag.Signature, err = r.tagSignature(obj)
+			if err != nil {
+				return err
+			}
+			tag.Commit, err = obj.Commit()
+			if err == object.ErrUnsupportedObject {
+				return nil // tag does not point to a commit
+			}
+		case plumbing.ErrObjectNotFound:
+			tag.Commit, err = r.git.CommitObject(ref.Hash())
+			if err == plumbing.ErrObjectNotFound {
+				return nil // tag does not point to a commit
+			} else if err == nil {
+				tag.Tagger = tag.Commit.Committer
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		tag.Tagger = r.mailmap.apply(tag.Tagger)
+		tags = append(tags, tag)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by tagger date, latest first. Tags with the same date are
+	// sorted by name to ensure a stable order across builds.
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, b := tags[i], tags[j]
+		if !a.Tagger.When.Equal(b.Tagger.When) {
+			return a.Tagger.When.After(b.Tagger.When)
+		}
+		return a.Name < b.Name
+	})
+	return tags, nil
+}
+
+// TagsByCommit returns the names of all tags pointing to a given commit.
+func (r *Repo) TagsByCommit() (map[plumbing.Hash][]string, error) {
+	root := r.root()
+	if root.tagsCache != nil {
+		return root.tagsCache, nil
+	}
+
+	tags, err := root.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[plumbing.Hash][]string)
+	for _, tag := range tags {
+		m[tag.Commit.Hash] = append(m[tag.Commit.Hash], tag.Name)
+	}
+
+	root.tagsCache = m
+	return m, nil
+}
+
+// Returns a map of all tag names to the hash of the referenced object.
+func (r *Repo) tagRefs() (map[string]plumbing.Hash, error) {
+	iter, err := r.git.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]plumbing.Hash)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		refs[ref.Name().Short()] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// Resolves the commit for the object referenced by a tag.
+func (r *Repo) tagCommit(h plumbing.Hash) (*object.Commit, error) {
+	tag, err := r.git.TagObject(h)
+	if err == nil {
+		return tag.Commit()
+	} else if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return r.git.CommitObject(h)
+	}
+
+	return nil, err
+}
+
+// Returns the commits referenced by tags which were added, removed, or
+// moved since the state was recorded. Pages listing the tags of these
+// commits need to be rewritten.
+func (r *Repo) retaggedCommits() (map[plumbing.Hash]bool, error) {
+	root := r.root()
+
+	commits := make(map[plumbing.Hash]bool)
+	add := func(h plumbing.Hash) error {
+		commit, err := root.tagCommit(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) || errors.Is(err, object.ErrUnsupportedObject) {
+			return nil // tag does not point to an (existing) commit
+		} else if err != nil {
+			return err
+		}
+
+		commits[commit.Hash] = true
+		return nil
+	}
+
+	for name, h := range root.curTags {
+		prev, ok := root.prevTags[name]
+		if ok && prev == h {
+			continue
+		}
+		if err := add(h); err != nil {
+			return nil, err
+		}
+		if ok {
+			if err := add(prev); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for name, h := range root.prevTags {
+		if _, ok := root.curTags[name]; !ok {
+			if err := add(h); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return commits, nil
+}
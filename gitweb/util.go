@@ -1,39 +1,50 @@
 package gitweb
 
 import (
-	"io"
+	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
-
-	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/hash"
 )
 
-var readmeRegex = regexp.MustCompile(`README|(README\.[a-zA-Z0-9]+)`)
+// Returns the position of the first README pattern matching the given
+// file name, or -1 if the name does not match any pattern. Patterns are
+// matched case-insensitively.
+func (c *Config) readmeRank(name string) int {
+	name = strings.ToLower(name)
+	for i, pattern := range c.Readmes {
+		// Patterns are validated when loading the configuration.
+		matched, _ := path.Match(strings.ToLower(pattern), name)
+		if matched {
+			return i
+		}
+	}
 
-func isReadme(fp string) bool {
-	name := filepath.Base(fp)
-	return readmeRegex.MatchString(name)
+	return -1
 }
 
-func readHashFile(r io.Reader) (plumbing.Hash, error) {
-	var hashData = make([]byte, hash.HexSize)
-	_, err := r.Read(hashData)
-	if err != nil {
-		return plumbing.Hash{}, err
+func (c *Config) isReadme(fp string) bool {
+	return c.readmeRank(filepath.Base(fp)) != -1
+}
+
+// Returns the amount of lines in s, a missing terminating newline is ignored.
+func countLines(s string) int {
+	if s == "" {
+		return 0
 	}
 
-	// TODO: Consider building our own hex decoder?
-	return plumbing.NewHash(string(hashData)), nil
+	n := strings.Count(s, "\n")
+	if s[len(s)-1] != '\n' {
+		n++
+	}
+	return n
 }
 
-func repoTitle(path string) string {
-	title := filepath.Base(path)
-	ext := strings.LastIndex(title, ".git")
+func repoName(path string) string {
+	name := filepath.Base(path)
+	ext := strings.LastIndex(name, ".git")
 	if ext > 0 {
-		title = title[0:ext]
+		name = name[0:ext]
 	}
 
-	return title
+	return name
 }
@@ -0,0 +1,392 @@
+package gitweb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	pgpSigPrefix = "-----BEGIN PGP SIGNATURE-----"
+	sshSigPrefix = "-----BEGIN SSH SIGNATURE-----"
+	sshSigSuffix = "-----END SSH SIGNATURE-----"
+
+	// Magic preamble and namespace of SSH signatures created by git.
+	sshSigMagic     = "SSHSIG"
+	sshSigNamespace = "git"
+)
+
+type SignatureStatus int
+
+const (
+	// Valid signature by a known key.
+	Verified SignatureStatus = iota
+	// Signature by a known key, which is not valid.
+	Unverified
+	// Signature by a key which is neither in the keyring nor in the
+	// allowed signers file, or of an unsupported type.
+	UnknownKey
+)
+
+// Signature represents the verification result for a signed object.
+type Signature struct {
+	Status SignatureStatus
+	Signer string // Identity of the signing key, empty unless verified
+}
+
+func (s *Signature) IsVerified() bool {
+	return s.Status == Verified
+}
+
+func (s *Signature) IsUnknownKey() bool {
+	return s.Status == UnknownKey
+}
+
+// Entry of an SSH allowed signers file, see ssh-keygen(1).
+type allowedSigner struct {
+	principals []string
+	key        ssh.PublicKey
+}
+
+// Verifier for signatures of commits and tags, the result for each
+// object is only computed once.
+type verifier struct {
+	keyring string // Armored OpenPGP keyring
+	signers []allowedSigner
+	cache   map[plumbing.Hash]*Signature
+	digest  string // Hash of the keyring and the allowed signers file
+}
+
+// Parses an SSH allowed signers file. Certificate authorities are not
+// supported and entries which are restricted to other namespaces are
+// skipped.
+func parseAllowedSigners(data []byte) ([]allowedSigner, error) {
+	var signers []allowedSigner
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i == -1 {
+			return nil, fmt.Errorf("invalid allowed signer %q", line)
+		}
+		principals := line[0:i]
+		key, _, options, _, err := ssh.ParseAuthorizedKey([]byte(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed signer %q: %w", line, err)
+		}
+
+		skip := false
+		for _, opt := range options {
+			name, value, _ := strings.Cut(opt, "=")
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				skip = true
+			case "namespaces":
+				value = strings.Trim(value, `"`)
+				skip = !strings.Contains(","+value+",", ","+sshSigNamespace+",")
+			}
+		}
+		if skip {
+			continue
+		}
+
+		signers = append(signers, allowedSigner{
+			principals: strings.Split(strings.Trim(principals, `"`), ","),
+			key:        key,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return signers, nil
+}
+
+// Reads an OpenPGP keyring, either armored or binary, and returns it in
+// armored form as expected by go-git.
+func readKeyring(data []byte) (string, error) {
+	_, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err == nil {
+		return string(data), nil
+	}
+
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, entity := range keyring {
+		err = entity.Serialize(w)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Loads the configured keyring and allowed signers file, relative paths
+// are relative to the repository. Returns nil if neither is configured.
+func (r *Repo) loadVerifier() (*verifier, error) {
+	if r.Conf.Keyring == "" && r.Conf.AllowedSigners == "" {
+		return nil, nil
+	}
+
+	v := &verifier{cache: make(map[plumbing.Hash]*Signature)}
+	h := sha256.New()
+	read := func(fp string) ([]byte, error) {
+		if !filepath.IsAbs(fp) {
+			fp = filepath.Join(r.Path, fp)
+		}
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+		return data, nil
+	}
+
+	if r.Conf.Keyring != "" {
+		data, err := read(r.Conf.Keyring)
+		if err != nil {
+			return nil, err
+		}
+		v.keyring, err = readKeyring(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyring: %w", err)
+		}
+	}
+	if r.Conf.AllowedSigners != "" {
+		data, err := read(r.Conf.AllowedSigners)
+		if err != nil {
+			return nil, err
+		}
+		v.signers, err = parseAllowedSigners(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v.digest = hex.EncodeToString(h.Sum(nil))
+	return v, nil
+}
+
+// Returns the verifier of the repository, the configured keys are only
+// loaded once and only if signatures are verified. Returns nil if
+// verification is not configured.
+func (r *Repo) signatureVerifier() (*verifier, error) {
+	root := r.root()
+	if !root.verifierLoaded {
+		v, err := root.loadVerifier()
+		if err != nil {
+			return nil, err
+		}
+		root.verifier, root.verifierLoaded = v, true
+	}
+
+	return root.verifier, nil
+}
+
+// LoadKeys loads the keyring and the allowed signers file used to verify
+// signatures. Unless loaded explicitly, they are loaded on first use.
+func (r *Repo) LoadKeys() error {
+	_, err := r.signatureVerifier()
+	return err
+}
+
+// Returns the hash of the keys used for verification, if any.
+func (v *verifier) keys() string {
+	if v == nil {
+		return ""
+	}
+	return v.digest
+}
+
+// Verifies an OpenPGP signature using the given function, which is either
+// object.Commit.Verify or object.Tag.Verify, against the keyring.
+func (v *verifier) verifyPGP(check func(string) (*openpgp.Entity, error)) *Signature {
+	entity, err := check(v.keyring)
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return &Signature{Status: UnknownKey}
+	} else if err != nil {
+		return &Signature{Status: Unverified}
+	}
+
+	signer := ""
+	if id := entity.PrimaryIdentity(); id != nil {
+		signer = id.Name
+	}
+	return &Signature{Status: Verified, Signer: signer}
+}
+
+// Verifies an armored SSH signature, as described in the PROTOCOL.sshsig
+// file of OpenSSH, against the allowed signers.
+func (v *verifier) verifySSH(message []byte, armored string) *Signature {
+	armored = strings.TrimSpace(armored)
+	armored = strings.TrimPrefix(armored, sshSigPrefix)
+	armored = strings.TrimSuffix(armored, sshSigSuffix)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(armored), ""))
+	if err != nil || !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return &Signature{Status: Unverified}
+	}
+
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	err = ssh.Unmarshal(blob[len(sshSigMagic):], &sig)
+	if err != nil {
+		return &Signature{Status: Unverified}
+	}
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return &Signature{Status: Unverified}
+	}
+
+	var signer *allowedSigner
+	for i := range v.signers {
+		if bytes.Equal(v.signers[i].key.Marshal(), key.Marshal()) {
+			signer = &v.signers[i]
+			break
+		}
+	}
+	if signer == nil {
+		return &Signature{Status: UnknownKey}
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return &Signature{Status: Unverified}
+	}
+	h.Write(message)
+
+	var wire struct {
+		Format string
+		Blob   []byte
+		Rest   []byte `ssh:"rest"`
+	}
+	err = ssh.Unmarshal(sig.Signature, &wire)
+	if err != nil || sig.Version != 1 || sig.Namespace != sshSigNamespace {
+		return &Signature{Status: Unverified}
+	}
+
+	signed := ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sig.Namespace, sig.Reserved, sig.HashAlgorithm, h.Sum(nil)})
+	signed = append([]byte(sshSigMagic), signed...)
+
+	err = key.Verify(signed, &ssh.Signature{Format: wire.Format, Blob: wire.Blob, Rest: wire.Rest})
+	if err != nil {
+		return &Signature{Status: Unverified}
+	}
+	return &Signature{Status: Verified, Signer: strings.Join(signer.principals, ", ")}
+}
+
+// Verifies the signature of the object with the given hash. OpenPGP
+// signatures are checked using the given function, SSH signatures against
+// the object encoded by the given encoder, which must omit the signature.
+func (v *verifier) verify(h plumbing.Hash, sig string, checkPGP func(string) (*openpgp.Entity, error), encode func(plumbing.EncodedObject) error) (*Signature, error) {
+	if result, ok := v.cache[h]; ok {
+		return result, nil
+	}
+
+	var result *Signature
+	switch {
+	case strings.HasPrefix(sig, pgpSigPrefix) && v.keyring != "":
+		result = v.verifyPGP(checkPGP)
+	case strings.HasPrefix(sig, sshSigPrefix) && v.signers != nil:
+		obj := &plumbing.MemoryObject{}
+		err := encode(obj)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := obj.Reader()
+		if err != nil {
+			return nil, err
+		}
+		message, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		result = v.verifySSH(message, sig)
+	default:
+		result = &Signature{Status: UnknownKey}
+	}
+
+	v.cache[h] = result
+	return result, nil
+}
+
+// Signature returns the verification result for the signature of the
+// given commit. If the commit is not signed, or if neither a keyring nor
+// an allowed signers file is configured, nil is returned.
+func (r *Repo) Signature(c *object.Commit) (*Signature, error) {
+	if c.PGPSignature == "" {
+		return nil, nil
+	}
+	v, err := r.signatureVerifier()
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	return v.verify(c.Hash, c.PGPSignature, c.Verify, c.EncodeWithoutSignature)
+}
+
+// Returns the verification result for the signature of the given tag
+// object, nil is returned under the same conditions as for commits.
+func (r *Repo) tagSignature(t *object.Tag) (*Signature, error) {
+	if t.PGPSignature == "" {
+		return nil, nil
+	}
+	v, err := r.signatureVerifier()
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	return v.verify(t.Hash, t.PGPSignature, t.Verify, t.EncodeWithoutSignature)
+}
@@ -0,0 +1,81 @@
+package gitweb
+
+import "testing"
+
+// Commit message signed with the key of alice, see below.
+const sshMessage = `tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904
+author Alice <alice@example.org> 1700000000 +0000
+committer Alice <alice@example.org> 1700000000 +0000
+
+Initial commit
+`
+
+const (
+	aliceSigner = `alice@example.org ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAILr1V2jWVn4Vl07xoJAeYdcANsH6yMbr3XAVAZmGWSaq`
+	eveSigner   = `eve@example.org ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIASmGchKMkRmkOREBIijwi9q0X7yYBpaz8hVEh5iFixn`
+)
+
+const (
+	// Created with: ssh-keygen -Y sign -n git
+	sshSigValid = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAguvVXaNZWfhWXTvGgkB5h1wA2wf
+rIxuvdcBUBmYZZJqoAAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5
+AAAAQNeuDpQeWRjNXU6/KDIcyaNHibludwU+X/trTqYaCEWdamFL53S5TtFEPTEbn1T5wj
+Z6AH4VBIRwgT0WhT1reAQ=
+-----END SSH SIGNATURE-----
+`
+
+	// Created with: ssh-keygen -Y sign -n file
+	sshSigWrongNamespace = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAguvVXaNZWfhWXTvGgkB5h1wA2wf
+rIxuvdcBUBmYZZJqoAAAAEZmlsZQAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEBjR4rm56VjsNfyGSzEvZrDq4KI0uGiqBKmdfeHTpWNktKtFCE1CwUHxb8IG927T+
+U6N1hyc7P20WAusTvxJKgJ
+-----END SSH SIGNATURE-----
+`
+
+	// Valid signature over the SHA-1 hash of the message, which is not
+	// supported by the SSHSIG format.
+	sshSigSHA1 = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAguvVXaNZWfhWXTvGgkB5h1wA2wf
+rIxuvdcBUBmYZZJqoAAAADZ2l0AAAAAAAAAARzaGExAAAAUwAAAAtzc2gtZWQyNTUxOQAA
+AEDLF5amCQV7ropabTBCDEi8aJrDJwqXinasd8cNUuq82HVL0dR4RTx5AjHyP1SwE0YkPs
+MwUvtpCDm/OtxIJKMP
+-----END SSH SIGNATURE-----
+`
+)
+
+func TestVerifySSH(t *testing.T) {
+	tests := []struct {
+		name    string
+		signers string
+		message string
+		sig     string
+		status  SignatureStatus
+		signer  string
+	}{
+		{"valid", aliceSigner, sshMessage, sshSigValid, Verified, "alice@example.org"},
+		{"tampered message", aliceSigner, sshMessage + "\n", sshSigValid, Unverified, ""},
+		{"unknown key", eveSigner, sshMessage, sshSigValid, UnknownKey, ""},
+		{"wrong namespace", aliceSigner, sshMessage, sshSigWrongNamespace, Unverified, ""},
+		{"unsupported hash", aliceSigner, sshMessage, sshSigSHA1, Unverified, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signers, err := parseAllowedSigners([]byte(test.signers))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := &verifier{signers: signers}
+			result := v.verifySSH([]byte(test.message), test.sig)
+			if result.Status != test.status {
+				t.Errorf("got status %v, expected %v", result.Status, test.status)
+			}
+			if result.Signer != test.signer {
+				t.Errorf("got signer %q, expected %q", result.Signer, test.signer)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package highlight implements a simple syntax highlighter for common
+// programming languages. Keywords, strings, comments, and numbers are
+// enclosed in span elements with the classes hl-keyword, hl-string,
+// hl-comment, and hl-number respectively.
+package highlight
+
+import (
+	"html/template"
+	"strings"
+)
+
+// lineWriter collects HTML for each line of a file.
+type lineWriter struct {
+	lines []template.HTML
+	cur   strings.Builder
+}
+
+// Writes text of the given class, an empty class denotes plain text.
+// Elements are closed at the end of each line, thereby each line can be
+// used on its own.
+func (w *lineWriter) write(class, text string) {
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			w.lines = append(w.lines, template.HTML(w.cur.String()))
+			w.cur.Reset()
+		}
+		if part == "" {
+			continue
+		}
+
+		escaped := template.HTMLEscapeString(part)
+		if class == "" {
+			w.cur.WriteString(escaped)
+		} else {
+			w.cur.WriteString(`<span class="hl-` + class + `">` + escaped + "</span>")
+		}
+	}
+}
+
+func (w *lineWriter) finish() []template.HTML {
+	return append(w.lines, template.HTML(w.cur.String()))
+}
+
+func isIdentStart(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isIdent(b byte) bool {
+	return isIdentStart(b) || (b >= '0' && b <= '9')
+}
+
+// Returns the length of the string starting at s, which starts with the
+// given quote. Strings end at the closing quote or the end of the line.
+func quoted(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+	return len(s)
+}
+
+// Returns the length of s up to and including the delimiter, or the length
+// of s if it does not contain the delimiter.
+func until(s string, delim string) int {
+	idx := strings.Index(s, delim)
+	if idx == -1 {
+		return len(s)
+	}
+	return idx + len(delim)
+}
+
+// Returns the length of the first line of s, excluding the newline.
+func lineEnd(s string) int {
+	idx := strings.IndexByte(s, '\n')
+	if idx == -1 {
+		return len(s)
+	}
+	return idx
+}
+
+// Lines returns the highlighted HTML of each line of the given contents,
+// the language is detected from the file name. Lines of files in unknown
+// languages are only escaped. A terminating newline is ignored and
+// carriage returns of DOS line endings are removed.
+func Lines(name, contents string) []template.HTML {
+	contents = strings.ReplaceAll(contents, "\r\n", "\n")
+	contents = strings.TrimSuffix(contents, "\n")
+
+	lang := detect(name)
+	if lang == nil {
+		var w lineWriter
+		w.write("", contents)
+		return w.finish()
+	}
+
+	var w lineWriter
+	s := contents
+
+	var plain int // length of pending plain text
+	emit := func(class string, n int) {
+		w.write("", s[0:plain])
+		w.write(class, s[plain:plain+n])
+		s = s[plain+n:]
+		plain = 0
+	}
+
+outer:
+	for plain < len(s) {
+		rest := s[plain:]
+		for _, delims := range lang.blockComments {
+			if strings.HasPrefix(rest, delims[0]) {
+				emit("comment", len(delims[0])+until(rest[len(delims[0]):], delims[1]))
+				continue outer
+			}
+		}
+		for _, prefix := range lang.lineComments {
+			if strings.HasPrefix(rest, prefix) {
+				emit("comment", lineEnd(rest))
+				continue outer
+			}
+		}
+		for _, delim := range lang.rawQuotes {
+			if strings.HasPrefix(rest, delim) {
+				emit("string", len(delim)+until(rest[len(delim):], delim))
+				continue outer
+			}
+		}
+
+		b := rest[0]
+		switch {
+		case strings.IndexByte(lang.quotes, b) != -1:
+			emit("string", quoted(rest, b))
+		case isIdentStart(b):
+			n := 1
+			for n < len(rest) && isIdent(rest[n]) {
+				n++
+			}
+			if lang.keywords[rest[0:n]] {
+				emit("keyword", n)
+			} else {
+				plain += n
+			}
+		case b >= '0' && b <= '9':
+			n := 1
+			for n < len(rest) && (isIdent(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			emit("number", n)
+		default:
+			plain++
+		}
+	}
+	emit("", 0)
+
+	return w.finish()
+}
@@ -0,0 +1,139 @@
+package highlight
+
+import (
+	"path"
+	"strings"
+)
+
+// language describes the lexical structure of a programming language.
+type language struct {
+	keywords      map[string]bool
+	lineComments  []string
+	blockComments [][2]string
+
+	// Quotes of strings which may contain backslash escapes and do
+	// not span multiple lines, and delimiters of multi-line strings
+	// which do not support escapes.
+	quotes    string
+	rawQuotes []string
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cKeywords = `auto break case char const continue default do double else
+		enum extern float for goto if inline int long register restrict return
+		short signed sizeof static struct switch typedef union unsigned void
+		volatile while bool true false NULL`
+
+	golang = &language{
+		keywords: words(`break case chan const continue default defer else
+			fallthrough for func go goto if import interface map package range
+			return select struct switch type var true false nil iota`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		rawQuotes:     []string{"`"},
+	}
+	c = &language{
+		keywords:      words(cKeywords),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+	cpp = &language{
+		keywords: words(cKeywords + ` class namespace template typename public
+			private protected virtual override new delete this throw try catch
+			using nullptr constexpr noexcept operator friend explicit mutable`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+	java = &language{
+		keywords: words(`abstract boolean break byte case catch char class
+			const continue default do double else enum extends final finally
+			float for if implements import instanceof int interface long new
+			package private protected public return short static super switch
+			synchronized this throw throws try void volatile while true false
+			null var record`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+	javascript = &language{
+		keywords: words(`async await break case catch class const continue
+			debugger default delete do else export extends finally for function
+			if import in instanceof let new of return static super switch this
+			throw try typeof var void while yield true false null undefined
+			interface type enum implements`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		rawQuotes:     []string{"`"},
+	}
+	rust = &language{
+		keywords: words(`as async await break const continue crate dyn else
+			enum extern false fn for if impl in let loop match mod move mut pub
+			ref return self Self static struct super trait true type unsafe use
+			where while`),
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"`,
+	}
+	python = &language{
+		keywords: words(`and as assert async await break class continue def
+			del elif else except finally for from global if import in is lambda
+			nonlocal not or pass raise return try while with yield True False
+			None`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		rawQuotes:    []string{`"""`, `'''`},
+	}
+	shell = &language{
+		keywords: words(`if then else elif fi case esac for while until do
+			done in function return local export readonly set unset shift exit`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	makefile = &language{
+		keywords:     words(`ifeq ifneq ifdef ifndef else endif include define endef export`),
+		lineComments: []string{"#"},
+	}
+)
+
+var extensions = map[string]*language{
+	".go":   golang,
+	".c":    c,
+	".h":    c,
+	".cc":   cpp,
+	".cpp":  cpp,
+	".cxx":  cpp,
+	".hh":   cpp,
+	".hpp":  cpp,
+	".java": java,
+	".js":   javascript,
+	".mjs":  javascript,
+	".ts":   javascript,
+	".rs":   rust,
+	".py":   python,
+	".sh":   shell,
+	".bash": shell,
+	".mk":   makefile,
+}
+
+// Returns the language of the file with the given name, nil if unknown.
+func detect(name string) *language {
+	base := path.Base(name)
+	switch base {
+	case "Makefile", "GNUmakefile", "makefile":
+		return makefile
+	}
+
+	return extensions[strings.ToLower(path.Ext(base))]
+}